@@ -1,10 +1,41 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/dikkadev/cland/internal/api"
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/internal/push"
 	"github.com/dikkadev/cland/pkg/exchange"
 	"github.com/dikkadev/prettyslog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	shutdownTimeout = 30 * time.Second
+
+	heartbeatTopic    = "cland/heartbeat"
+	heartbeatInterval = 5 * time.Minute
+)
+
+// The admin API requires basic auth with these credentials. Without them it is only
+// served on localhost. Either way, requests from the comma separated addresses in
+// trustedProxiesEnv are attributed to the user in their X-Forwarded-User header.
+const (
+	adminUserEnv      = "CLAND_ADMIN_USER"
+	adminPasswordEnv  = "CLAND_ADMIN_PASSWORD"
+	trustedProxiesEnv = "CLAND_TRUSTED_PROXIES"
 )
 
 func main() {
@@ -12,11 +43,95 @@ func main() {
 
 	slog.SetDefault(slog.New(logger))
 
-	handler := exchange.NewHandler("./tmp/input", "./tmp/error")
-	err := handler.Start()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := os.MkdirAll("./tmp", 0755); err != nil {
+		panic(err)
+	}
+	database, err := db.NewLibSQL("file:./tmp/cland.db")
+	if err != nil {
+		panic(err)
+	}
+	defer database.Close()
+	if err := database.Initialize(ctx); err != nil {
+		panic(err)
+	}
+
+	handler := exchange.NewHandler("./tmp/input", "./tmp/error",
+		exchange.WithStore(database),
+		exchange.WithDeliverer(push.NewSender(database, nil)),
+		exchange.WithMetrics(prometheus.DefaultRegisterer),
+		exchange.WithHeartbeat(heartbeatTopic, heartbeatInterval))
+	// The handler gets its own context so in-flight files can drain after a signal.
+	err = handler.Start(context.Background())
 	if err != nil {
 		panic(err)
 	}
 
-	select {}
+	apiOpts := []api.Option{api.WithDevices(database), api.WithAuditLog(database)}
+	addr := ":8080"
+	user, password := os.Getenv(adminUserEnv), os.Getenv(adminPasswordEnv)
+	if user != "" && password != "" {
+		apiOpts = append(apiOpts, api.WithBasicAuth(checkCredentials(user, password)))
+	} else {
+		addr = "localhost:8080"
+		slog.Warn("No admin credentials configured, serving the API on localhost only", "user", adminUserEnv, "password", adminPasswordEnv)
+	}
+	proxies, err := parseAddrs(os.Getenv(trustedProxiesEnv))
+	if err != nil {
+		panic(err)
+	}
+	if len(proxies) > 0 {
+		apiOpts = append(apiOpts, api.WithTrustedProxies(proxies...))
+	}
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: api.New(handler, apiOpts...),
+	}
+	go func() {
+		slog.Info("Serving API", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down API", "err", err)
+	}
+	if err := handler.Stop(shutdownCtx); err != nil {
+		slog.Error("Error stopping handler", "err", err)
+	}
+}
+
+// checkCredentials accepts only user and password, comparing in constant time.
+func checkCredentials(user, password string) func(string, string) bool {
+	return func(gotUser, gotPassword string) bool {
+		userOK := subtle.ConstantTimeCompare([]byte(gotUser), []byte(user)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(gotPassword), []byte(password)) == 1
+		return userOK && passwordOK
+	}
+}
+
+// parseAddrs parses a comma separated list of IP addresses.
+func parseAddrs(list string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trusted proxy %q: %w", field, err)
+		}
+		addrs = append(addrs, addr.Unmap())
+	}
+	return addrs, nil
 }
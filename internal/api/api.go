@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/internal/middleware"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Reprocessor interface {
+	Reprocess(ctx context.Context, name string) (*exchange.Notification, error)
+}
+
+type DeviceRegistrar interface {
+	RegisterDevice(ctx context.Context, device db.Device) error
+}
+
+type AuditLog interface {
+	middleware.AuditRecorder
+	ListAuditEntries(ctx context.Context, limit int) ([]db.AuditEntry, error)
+}
+
+type Server struct {
+	reprocessor Reprocessor
+	devices     DeviceRegistrar
+	audit       AuditLog
+	mux         *http.ServeMux
+	handler     http.Handler
+
+	basicAuth func(user, password string) bool
+	proxies   []netip.Addr
+}
+
+type Option func(*Server)
+
+// WithDevices enables POST /admin/devices to register devices.
+func WithDevices(devices DeviceRegistrar) Option {
+	return func(s *Server) {
+		s.devices = devices
+	}
+}
+
+// WithAuditLog records every mutating request in log and serves it on GET /admin/audit.
+func WithAuditLog(log AuditLog) Option {
+	return func(s *Server) {
+		s.audit = log
+	}
+}
+
+// WithBasicAuth requires every request to carry basic auth credentials check accepts,
+// unless a trusted proxy already authenticated it. The user becomes the audit actor.
+func WithBasicAuth(check func(user, password string) bool) Option {
+	return func(s *Server) {
+		s.basicAuth = check
+	}
+}
+
+// WithTrustedProxies trusts the X-Forwarded-User header of requests from proxies, reverse
+// proxies that authenticate users themselves. Without it the header is ignored.
+func WithTrustedProxies(proxies ...netip.Addr) Option {
+	return func(s *Server) {
+		s.proxies = proxies
+	}
+}
+
+func New(reprocessor Reprocessor, opts ...Option) *Server {
+	s := &Server{
+		reprocessor: reprocessor,
+		mux:         http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux.HandleFunc("POST /admin/reprocess", s.handleReprocess)
+	s.mux.Handle("GET /metrics", promhttp.Handler())
+	if s.devices != nil {
+		s.mux.HandleFunc("POST /admin/devices", s.handleRegisterDevice)
+	}
+
+	s.handler = s.mux
+	if s.audit != nil {
+		s.mux.HandleFunc("GET /admin/audit", s.handleAudit)
+		s.handler = middleware.Audit(s.audit)(s.mux)
+	}
+	if s.basicAuth != nil {
+		s.handler = middleware.BasicAuth(s.basicAuth)(s.handler)
+	}
+	if len(s.proxies) > 0 {
+		s.handler = middleware.TrustedProxy(s.proxies...)(s.handler)
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+type notificationResponse struct {
+	ID       int               `json:"id,omitempty"`
+	Topic    string            `json:"topic"`
+	Metadata map[string]string `json:"metadata"`
+	Message  string            `json:"message"`
+	Format   exchange.Format   `json:"format"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	Type  string `json:"type,omitempty"`
+}
+
+func (s *Server) handleReprocess(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		File string `json:"file"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	middleware.SetAuditTarget(r.Context(), req.File)
+	notif, err := s.reprocessor.Reprocess(r.Context(), req.File)
+	switch {
+	case errors.Is(err, exchange.ErrInvalidPath):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case errors.Is(err, exchange.ErrFileNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case errors.Is(err, exchange.ErrFileBusy):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error(), Type: errorType(err)})
+	default:
+		writeJSON(w, http.StatusOK, notificationResponse{
+			ID:       notif.ID(),
+			Topic:    notif.Topic,
+			Metadata: notif.Metadata,
+			Message:  notif.Message,
+			Format:   notif.Format,
+		})
+	}
+}
+
+type deviceRequest struct {
+	ID         string `json:"id"`
+	PublicKey  string `json:"public_key"`
+	SigningKey string `json:"signing_key,omitempty"`
+	Endpoint   string `json:"endpoint"`
+	Locale     string `json:"locale"`
+}
+
+func (s *Server) handleRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	var req deviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+	middleware.SetAuditTarget(r.Context(), req.ID)
+
+	err := s.devices.RegisterDevice(r.Context(), db.Device{
+		ID:         req.ID,
+		PublicKey:  req.PublicKey,
+		SigningKey: req.SigningKey,
+		Endpoint:   req.Endpoint,
+		Locale:     req.Locale,
+	})
+	switch {
+	case errors.Is(err, db.ErrEmptyDeviceID), errors.Is(err, db.ErrEmptyPublicKey), errors.Is(err, db.ErrInvalidEndpoint):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case err != nil:
+		slog.Error("Error registering device", "device", req.ID, "err", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusCreated, req)
+	}
+}
+
+type auditEntryResponse struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Status    int       `json:"status"`
+	Outcome   string    `json:"outcome"`
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "limit must be a non-negative integer"})
+			return
+		}
+		limit = n
+	}
+
+	entries, err := s.audit.ListAuditEntries(r.Context(), limit)
+	if err != nil {
+		slog.Error("Error listing audit entries", "err", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := make([]auditEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		resp = append(resp, auditEntryResponse(e))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// errorType names the concrete error type, e.g. "NoTopicError", so clients can branch on it.
+func errorType(err error) string {
+	name := fmt.Sprintf("%T", err)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error writing response", "err", err)
+	}
+}
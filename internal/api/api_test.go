@@ -0,0 +1,121 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/api"
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupHandler(t *testing.T) *exchange.Handler {
+	dir := t.TempDir()
+	return exchange.NewHandler(filepath.Join(dir, "input"), filepath.Join(dir, "error"))
+}
+
+func post(t *testing.T, srv http.Handler, path, body string) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+	return rec, resp
+}
+
+func TestReprocess(t *testing.T) {
+	handler := setupHandler(t)
+	srv := api.New(handler)
+
+	require.NoError(t, os.WriteFile(filepath.Join(handler.InputDir, "good.txt"),
+		[]byte("topic\nkey: value\n---\nmessage"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(handler.ErrorDir, "broken.txt"),
+		[]byte("---\nmessage"), 0644))
+
+	t.Run("valid file", func(t *testing.T) {
+		rec, resp := post(t, srv, "/admin/reprocess", `{"file": "good.txt"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "topic", resp["topic"])
+		assert.Equal(t, "message", resp["message"])
+		assert.Equal(t, map[string]any{"key": "value"}, resp["metadata"])
+
+		// Reprocessing is side effect free.
+		assert.FileExists(t, filepath.Join(handler.InputDir, "good.txt"))
+	})
+
+	t.Run("malformed file", func(t *testing.T) {
+		rec, resp := post(t, srv, "/admin/reprocess", `{"file": "broken.txt"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, "NoTopicError", resp["type"])
+		assert.Contains(t, resp["error"], "broken.txt")
+		assert.FileExists(t, filepath.Join(handler.ErrorDir, "broken.txt"))
+	})
+
+	t.Run("priority error dir", func(t *testing.T) {
+		dir := filepath.Join(handler.ErrorDir, "critical")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "urgent.txt"), []byte("topic\n---\nurgent"), 0644))
+
+		rec, resp := post(t, srv, "/admin/reprocess", `{"file": "urgent.txt"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "urgent", resp["message"])
+	})
+
+	t.Run("path traversal", func(t *testing.T) {
+		for _, name := range []string{"../error/broken.txt", "/etc/passwd", "..", ""} {
+			rec, _ := post(t, srv, "/admin/reprocess", `{"file": "`+name+`"}`)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+		}
+	})
+
+	t.Run("unknown file", func(t *testing.T) {
+		rec, _ := post(t, srv, "/admin/reprocess", `{"file": "missing.txt"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		rec, _ := post(t, srv, "/admin/reprocess", `not json`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestReprocessStoresNotification(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory(db.WithMemoryDedupWindow(time.Hour))
+	defer store.Close()
+	dir := t.TempDir()
+	handler := exchange.NewHandler(filepath.Join(dir, "input"), filepath.Join(dir, "error"), exchange.WithStore(store))
+	srv := api.New(handler)
+
+	path := filepath.Join(handler.ErrorDir, "fixed.txt")
+	require.NoError(t, os.WriteFile(path, []byte("topic\n---\nmessage"), 0644))
+
+	rec, resp := post(t, srv, "/admin/reprocess", `{"file": "fixed.txt"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotZero(t, resp["id"])
+
+	stored, err := store.GetNotification(ctx, int(resp["id"].(float64)))
+	require.NoError(t, err)
+	assert.Equal(t, "topic", stored.Topic)
+	assert.Equal(t, "message", stored.Message)
+	assert.NoFileExists(t, path, "a stored file is consumed")
+
+	// The same content again is a duplicate, it is consumed without a second row.
+	require.NoError(t, os.WriteFile(path, []byte("topic\n---\nmessage"), 0644))
+	rec, _ = post(t, srv, "/admin/reprocess", `{"file": "fixed.txt"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NoFileExists(t, path)
+	count, err := store.CountNotifications(ctx, db.NotificationFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/dikkadev/cland/internal/api"
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAuditDB(t *testing.T) *db.LibSQL {
+	database, err := db.NewLibSQL("file::memory:?cache=shared")
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	require.NoError(t, database.Initialize(context.Background()))
+	return database
+}
+
+func auditEntries(t *testing.T, srv http.Handler, user, password string) []map[string]any {
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit", nil)
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var entries []map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	return entries
+}
+
+func registerDevice(srv http.Handler, body string, prepare func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/devices", strings.NewReader(body))
+	prepare(req)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDeviceRegistrationIsAudited(t *testing.T) {
+	ctx := context.Background()
+	database := setupAuditDB(t)
+	check := func(user, password string) bool { return user == "alice" && password == "secret" }
+	srv := api.New(setupHandler(t), api.WithDevices(database), api.WithAuditLog(database), api.WithBasicAuth(check))
+	asAlice := func(r *http.Request) { r.SetBasicAuth("alice", "secret") }
+
+	rec := registerDevice(srv, `{"id": "phone", "public_key": "key", "endpoint": "https://push.example.com/phone"}`, asAlice)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = registerDevice(srv, `{"id": "", "public_key": "key"}`, asAlice)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = registerDevice(srv, `{"id": "tablet", "public_key": "key"}`, func(r *http.Request) { r.SetBasicAuth("alice", "wrong") })
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = registerDevice(srv, `{"id": "tablet", "public_key": "key"}`, func(r *http.Request) {})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	devices, err := database.ListDevices(ctx)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+
+	entries := auditEntries(t, srv, "alice", "secret")
+	require.Len(t, entries, 2, "reading the audit log and rejected requests are not audited")
+
+	assert.Equal(t, "alice", entries[0]["actor"])
+	assert.Equal(t, "failure", entries[0]["outcome"])
+	assert.EqualValues(t, http.StatusBadRequest, entries[0]["status"])
+
+	assert.Equal(t, "alice", entries[1]["actor"])
+	assert.Equal(t, "POST /admin/devices", entries[1]["action"])
+	assert.Equal(t, "phone", entries[1]["target"])
+	assert.Equal(t, "success", entries[1]["outcome"])
+	assert.EqualValues(t, http.StatusCreated, entries[1]["status"])
+	assert.NotEmpty(t, entries[1]["timestamp"])
+}
+
+func TestAuditActorIsNotTakenFromTheRequest(t *testing.T) {
+	database := setupAuditDB(t)
+	srv := api.New(setupHandler(t), api.WithDevices(database), api.WithAuditLog(database),
+		api.WithTrustedProxies(netip.MustParseAddr("10.0.0.1")))
+
+	forged := func(r *http.Request) {
+		r.SetBasicAuth("mallory", "unchecked")
+		r.Header.Set("X-Forwarded-User", "root")
+	}
+	rec := registerDevice(srv, `{"id": "forged", "public_key": "key"}`, forged)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	viaProxy := func(r *http.Request) {
+		r.RemoteAddr = "10.0.0.1:43210"
+		r.Header.Set("X-Forwarded-User", "bob")
+	}
+	rec = registerDevice(srv, `{"id": "proxied", "public_key": "key"}`, viaProxy)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	entries := auditEntries(t, srv, "", "")
+	require.Len(t, entries, 2)
+	assert.Equal(t, "bob", entries[0]["actor"])
+	assert.Equal(t, "proxied", entries[0]["target"])
+	assert.Equal(t, "anonymous", entries[1]["actor"], "unchecked credentials and untrusted headers")
+	assert.Equal(t, "forged", entries[1]["target"])
+}
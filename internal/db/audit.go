@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEntry records one mutating admin action.
+type AuditEntry struct {
+	ID        int
+	Timestamp time.Time
+	Actor     string
+	Action    string
+	Target    string
+	Status    int
+	Outcome   string
+}
+
+func (s *LibSQL) RecordAuditEntry(ctx context.Context, entry AuditEntry) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var target any
+	if entry.Target != "" {
+		target = entry.Target
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO audit_log (actor, action, target, status, outcome) VALUES (?, ?, ?, ?, ?)",
+		entry.Actor, entry.Action, target, entry.Status, entry.Outcome); err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ListAuditEntries returns the newest entries first. A limit of zero or less returns all.
+func (s *LibSQL) ListAuditEntries(ctx context.Context, limit int) ([]AuditEntry, error) {
+	query := "SELECT audit_id, timestamp, actor, action, target, status, outcome FROM audit_log ORDER BY audit_id DESC"
+	args := []any{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		var target sql.NullString
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.Actor, &e.Action, &target, &e.Status, &e.Outcome); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.Target = target.String
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+	return entries, nil
+}
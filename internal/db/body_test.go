@@ -0,0 +1,74 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyOverflow(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t, db.WithBodyOverflowThreshold(64))
+	defer database.Close()
+
+	small := "fits inline"
+	large := strings.Repeat("x", 1024)
+
+	smallID, err := database.InsertNotification(ctx, exchange.Notification{Topic: "bodies", Message: small})
+	require.NoError(t, err)
+	largeID, err := database.InsertNotification(ctx, exchange.Notification{Topic: "bodies", Message: large})
+	require.NoError(t, err)
+
+	info, err := database.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, info.OverflowBodies, "only the large body is stored separately")
+
+	got, err := database.GetNotification(ctx, smallID)
+	require.NoError(t, err)
+	assert.Equal(t, small, got.Message)
+
+	got, err = database.GetNotification(ctx, largeID)
+	require.NoError(t, err)
+	assert.Equal(t, large, got.Message)
+	assert.Equal(t, "bodies", got.Topic)
+
+	// Listing leaves the large body alone, GetNotification and the delivery queries load it.
+	matches, err := database.FindByFingerprint(ctx, exchange.Notification{Topic: "bodies", Message: large}.Fingerprint())
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Empty(t, matches[0].Message)
+	assert.True(t, matches[0].BodyOmitted)
+
+	listed, err := database.ListNotifications(ctx, db.NotificationFilter{Topic: "bodies"})
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.True(t, listed[0].BodyOmitted)
+	assert.False(t, listed[1].BodyOmitted)
+	assert.Equal(t, small, listed[1].Message)
+
+	pending, err := database.GetPendingNotifications(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, large, pending[1].Message)
+	assert.False(t, pending[1].BodyOmitted)
+
+	due, err := database.GetDueNotifications(ctx, time.Now(), 0)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, large, due[1].Message)
+}
+
+func TestGetNotificationNotFound(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	_, err := database.GetNotification(context.Background(), 42)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -6,6 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/dikkadev/cland/pkg/exchange"
 	_ "github.com/tursodatabase/libsql-client-go/libsql"
@@ -14,40 +19,127 @@ import (
 
 const (
 	MaxTopicNameLength = 255
+
+	MinPriority = 0
+	MaxPriority = 9
+
+	DefaultMaxMessageLength = 1 << 20
+	DefaultMaxMetadataBytes = 64 << 10
 )
 
 var (
-	ErrEmptyDeviceID  = errors.New("device ID cannot be empty")
-	ErrEmptyPublicKey = errors.New("public key cannot be empty")
-	ErrEmptyTopic     = errors.New("topic name cannot be empty")
-	ErrTopicTooLong   = errors.New("topic name exceeds maximum length")
-	ErrEmptyMessage   = errors.New("notification message cannot be empty")
+	ErrEmptyDeviceID    = errors.New("device ID cannot be empty")
+	ErrEmptyPublicKey   = errors.New("public key cannot be empty")
+	ErrEmptyTopic       = errors.New("topic name cannot be empty")
+	ErrTopicTooLong     = errors.New("topic name exceeds maximum length")
+	ErrInvalidTopicName = errors.New("topic name does not match the configured pattern")
+	ErrEmptyMessage     = errors.New("notification message cannot be empty")
+	ErrInvalidPriority  = errors.New("notification priority must be between 0 and 9")
+	ErrMessageTooLong   = errors.New("notification message exceeds maximum length")
+	ErrMetadataTooLarge = errors.New("notification metadata exceeds maximum size")
+	ErrInvalidEndpoint  = errors.New("device endpoint must be an absolute http(s) URL")
+
+	ErrDuplicateNotification = exchange.ErrDuplicateNotification
+	ErrInvalidNotification   = exchange.ErrInvalidNotification
 )
 
 type LibSQL struct {
 	db *sql.DB
+
+	fingerprintKeys   []string
+	onStatusChange    func(StatusChange)
+	descriptionPolicy TopicDescriptionPolicy
+	overflowThreshold int
+	topicPattern      *regexp.Regexp
+	maxMessageLength  int
+	maxMetadataBytes  int
+	dedupWindow       time.Duration
 }
 
-func NewLibSQL(url string) (*LibSQL, error) {
-	db, err := sql.Open("libsql", url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+type StatusChange struct {
+	NotificationID int
+	Old            NotificationStatus
+	New            NotificationStatus
+}
+
+type Option func(*LibSQL)
+
+// WithFingerprintMetadata includes the given metadata keys in the fingerprint stored
+// with each notification. By default only topic and message are hashed.
+func WithFingerprintMetadata(keys ...string) Option {
+	return func(s *LibSQL) {
+		s.fingerprintKeys = keys
 	}
-	return &LibSQL{db: db}, nil
 }
 
-func (s *LibSQL) Initialize(ctx context.Context) error {
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+// WithBodyOverflowThreshold stores messages longer than threshold bytes in the separate
+// notification_bodies table instead of inline. Zero keeps every message inline.
+func WithBodyOverflowThreshold(threshold int) Option {
+	return func(s *LibSQL) {
+		s.overflowThreshold = threshold
 	}
-	defer tx.Rollback()
+}
 
-	if _, err := tx.ExecContext(ctx, CREATE_ALL_TABLES); err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
+// WithTopicPattern rejects topic names that do not match pattern with ErrInvalidTopicName.
+// The pattern should be anchored, it is matched as is.
+func WithTopicPattern(pattern *regexp.Regexp) Option {
+	return func(s *LibSQL) {
+		s.topicPattern = pattern
 	}
+}
 
-	return tx.Commit()
+// WithMaxMessageLength rejects messages longer than n bytes with ErrMessageTooLong instead
+// of DefaultMaxMessageLength. Zero disables the limit.
+func WithMaxMessageLength(n int) Option {
+	return func(s *LibSQL) {
+		s.maxMessageLength = n
+	}
+}
+
+// WithMaxMetadataBytes rejects notifications whose metadata is larger than n bytes as JSON
+// with ErrMetadataTooLarge instead of DefaultMaxMetadataBytes. Zero disables the limit.
+func WithMaxMetadataBytes(n int) Option {
+	return func(s *LibSQL) {
+		s.maxMetadataBytes = n
+	}
+}
+
+// WithDedupWindow makes InsertNotification reject a notification with
+// ErrDuplicateNotification if one with the same content hash was stored within window.
+// Without it content hashes are not checked.
+func WithDedupWindow(window time.Duration) Option {
+	return func(s *LibSQL) {
+		s.dedupWindow = window
+	}
+}
+
+// WithStatusChangeHook calls fn after every committed notification status transition.
+// fn runs synchronously on the caller's goroutine and must not block.
+func WithStatusChangeHook(fn func(StatusChange)) Option {
+	return func(s *LibSQL) {
+		s.onStatusChange = fn
+	}
+}
+
+func NewLibSQL(url string, opts ...Option) (*LibSQL, error) {
+	db, err := sql.Open("libsql", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	s := &LibSQL{
+		db:               db,
+		maxMessageLength: DefaultMaxMessageLength,
+		maxMetadataBytes: DefaultMaxMetadataBytes,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Initialize brings the schema up to date by applying all pending migrations.
+func (s *LibSQL) Initialize(ctx context.Context) error {
+	return s.migrate(ctx, migrations)
 }
 
 func (s *LibSQL) Close() error {
@@ -64,28 +156,74 @@ func validateDevice(deviceID, publicKey string) error {
 	return nil
 }
 
-func validateTopic(topicName string) error {
+func validateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidEndpoint
+	}
+	return nil
+}
+
+func validateTopic(topicName string, pattern *regexp.Regexp) error {
 	if topicName == "" {
 		return ErrEmptyTopic
 	}
-	if len(topicName) > MaxTopicNameLength {
+	if utf8.RuneCountInString(topicName) > MaxTopicNameLength {
 		return ErrTopicTooLong
 	}
+	if pattern != nil && !pattern.MatchString(topicName) {
+		return ErrInvalidTopicName
+	}
+	return nil
+}
+
+// validateNotification checks notif against the topic rules and size limits, limits of
+// zero are not enforced. Its errors wrap ErrInvalidNotification as well, the exchange
+// handler does not retry those.
+func validateNotification(notif exchange.Notification, topicPattern *regexp.Regexp, maxMessageLength, maxMetadataBytes int) error {
+	if err := checkNotification(notif, topicPattern, maxMessageLength, maxMetadataBytes); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidNotification, err)
+	}
 	return nil
 }
 
-func validateNotification(notif exchange.Notification) error {
-	if err := validateTopic(notif.Topic); err != nil {
+func checkNotification(notif exchange.Notification, topicPattern *regexp.Regexp, maxMessageLength, maxMetadataBytes int) error {
+	if err := validateTopic(notif.Topic, topicPattern); err != nil {
 		return err
 	}
 	if notif.Message == "" {
 		return ErrEmptyMessage
 	}
+	if maxMessageLength > 0 && len(notif.Message) > maxMessageLength {
+		return ErrMessageTooLong
+	}
+	if maxMetadataBytes > 0 {
+		metadataJSON, err := json.Marshal(notif.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata into JSON: %w", err)
+		}
+		if len(metadataJSON) > maxMetadataBytes {
+			return ErrMetadataTooLarge
+		}
+	}
+	if notif.Priority < MinPriority || notif.Priority > MaxPriority {
+		return ErrInvalidPriority
+	}
 	return nil
 }
 
 func (s *LibSQL) InsertDevice(ctx context.Context, deviceID, publicKey string) error {
-	if err := validateDevice(deviceID, publicKey); err != nil {
+	return s.RegisterDevice(ctx, Device{ID: deviceID, PublicKey: publicKey})
+}
+
+func (s *LibSQL) RegisterDevice(ctx context.Context, device Device) error {
+	if err := validateDevice(device.ID, device.PublicKey); err != nil {
+		return err
+	}
+	if err := validateEndpoint(device.Endpoint); err != nil {
 		return err
 	}
 
@@ -95,8 +233,18 @@ func (s *LibSQL) InsertDevice(ctx context.Context, deviceID, publicKey string) e
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.ExecContext(ctx, "INSERT INTO devices (device_id, public_key) VALUES (?, ?)",
-		deviceID, publicKey); err != nil {
+	var signingKey, endpoint, locale any
+	if device.SigningKey != "" {
+		signingKey = device.SigningKey
+	}
+	if device.Endpoint != "" {
+		endpoint = device.Endpoint
+	}
+	if device.Locale != "" {
+		locale = device.Locale
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO devices (device_id, public_key, signing_key, endpoint, locale) VALUES (?, ?, ?, ?, ?)",
+		device.ID, device.PublicKey, signingKey, endpoint, locale); err != nil {
 		return fmt.Errorf("failed to insert device: %w", err)
 	}
 
@@ -104,7 +252,7 @@ func (s *LibSQL) InsertDevice(ctx context.Context, deviceID, publicKey string) e
 }
 
 func (s *LibSQL) GetOrCreateTopic(ctx context.Context, topicName string, description string) (int, error) {
-	if err := validateTopic(topicName); err != nil {
+	if err := validateTopic(topicName, s.topicPattern); err != nil {
 		return 0, err
 	}
 
@@ -142,8 +290,23 @@ func (s *LibSQL) GetOrCreateTopic(ctx context.Context, topicName string, descrip
 	return int(topicID), nil
 }
 
+// ValidateNotification reports whether InsertNotification would reject notif as invalid.
+func (s *LibSQL) ValidateNotification(notif exchange.Notification) error {
+	return validateNotification(notif, s.topicPattern, s.maxMessageLength, s.maxMetadataBytes)
+}
+
 func (s *LibSQL) InsertNotification(ctx context.Context, notif exchange.Notification) (int, error) {
-	if err := validateNotification(notif); err != nil {
+	if err := s.ValidateNotification(notif); err != nil {
+		return 0, err
+	}
+
+	// Reading before the transaction keeps it from having to upgrade a read lock, which
+	// deadlocks concurrent inserts. A racing insert with the same idempotency key is caught
+	// by the unique index instead.
+	if id, err := s.findByIdempotencyKey(ctx, s.db, notif.IdempotencyKey); err != nil || id != 0 {
+		return id, err
+	}
+	if err := s.checkDuplicate(ctx, s.db, notif.ContentHash); err != nil {
 		return 0, err
 	}
 
@@ -157,15 +320,42 @@ func (s *LibSQL) InsertNotification(ctx context.Context, notif exchange.Notifica
 	if err != nil {
 		return 0, fmt.Errorf("failed to get or create topic: %w", err)
 	}
+	if err := s.applyTopicDescription(ctx, tx, topicID, notif.Metadata[DescriptionMetadataKey]); err != nil {
+		return 0, err
+	}
 
 	metadataJSON, err := json.Marshal(notif.Metadata)
 	if err != nil {
 		return 0, fmt.Errorf("failed to marshal metadata into JSON: %w", err)
 	}
 
+	var sendAt any
+	if !notif.SendAt.IsZero() {
+		sendAt = notif.SendAt.UTC().Format(sqliteTimeFormat)
+	}
+
+	var contentHash, idempotencyKey any
+	if notif.ContentHash != "" {
+		contentHash = notif.ContentHash
+	}
+	if notif.IdempotencyKey != "" {
+		idempotencyKey = notif.IdempotencyKey
+	}
+
+	overflow := s.overflowThreshold > 0 && len(notif.Message) > s.overflowThreshold
+	inline := notif.Message
+	if overflow {
+		inline = ""
+	}
+
 	res, err := tx.ExecContext(ctx,
-		"INSERT INTO notifications (topic_id, message, metadata) VALUES (?, ?, ?)",
-		topicID, notif.Message, metadataJSON)
+		"INSERT INTO notifications (topic_id, message, metadata, fingerprint, priority, send_at, content_hash, idempotency_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		topicID, inline, metadataJSON, notif.Fingerprint(s.fingerprintKeys...), notif.Priority, sendAt, contentHash, idempotencyKey)
+	if err != nil && notif.IdempotencyKey != "" && isUniqueViolation(err) {
+		// A concurrent insert with the same key won, report the row it created.
+		tx.Rollback()
+		return s.findByIdempotencyKey(ctx, s.db, notif.IdempotencyKey)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert notification: %w", err)
 	}
@@ -175,6 +365,13 @@ func (s *LibSQL) InsertNotification(ctx context.Context, notif exchange.Notifica
 		return 0, fmt.Errorf("failed to get notification ID: %w", err)
 	}
 
+	if overflow {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO notification_bodies (notification_id, body) VALUES (?, ?)",
+			notificationID, notif.Message); err != nil {
+			return 0, fmt.Errorf("failed to insert notification body: %w", err)
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -182,51 +379,230 @@ func (s *LibSQL) InsertNotification(ctx context.Context, notif exchange.Notifica
 	return int(notificationID), nil
 }
 
+// findByIdempotencyKey returns the ID of the notification stored with key together with
+// ErrDuplicateNotification, or zero if there is none. Deleted notifications keep their key
+// until they are purged.
+func (s *LibSQL) findByIdempotencyKey(ctx context.Context, q queryRower, key string) (int, error) {
+	if key == "" {
+		return 0, nil
+	}
+
+	var id int
+	err := q.QueryRowContext(ctx, "SELECT notification_id FROM notifications WHERE idempotency_key = ?", key).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up idempotency key: %w", err)
+	}
+	return id, fmt.Errorf("notification %d has idempotency key %q: %w", id, key, ErrDuplicateNotification)
+}
+
+func isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// checkDuplicate returns ErrDuplicateNotification if a notification with contentHash was
+// stored within the dedup window. Deleted notifications do not count.
+func (s *LibSQL) checkDuplicate(ctx context.Context, q queryRower, contentHash string) error {
+	if contentHash == "" || s.dedupWindow <= 0 {
+		return nil
+	}
+
+	var id int
+	err := q.QueryRowContext(ctx,
+		`SELECT notification_id FROM notifications
+		WHERE content_hash = ? AND deleted_at IS NULL AND timestamp >= datetime('now', ?)
+		LIMIT 1`,
+		contentHash, fmt.Sprintf("-%d seconds", int64(s.dedupWindow/time.Second))).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check for duplicate notification: %w", err)
+	}
+	return fmt.Errorf("notification %d has the same content: %w", id, ErrDuplicateNotification)
+}
+
+// MarkNotificationSent moves a notification from INPUT to SENT. Unknown notifications and
+// ones that already left INPUT are silently skipped, use TryMarkNotificationSent to tell.
 func (s *LibSQL) MarkNotificationSent(ctx context.Context, notificationID int) error {
+	_, err := s.TryMarkNotificationSent(ctx, notificationID)
+	return err
+}
+
+// MarkNotificationError is MarkNotificationSent for the ERROR status.
+func (s *LibSQL) MarkNotificationError(ctx context.Context, notificationID int) error {
+	_, err := s.TryMarkNotificationError(ctx, notificationID)
+	return err
+}
+
+// TryMarkNotificationSent is MarkNotificationSent but reports whether the status changed.
+func (s *LibSQL) TryMarkNotificationSent(ctx context.Context, notificationID int) (bool, error) {
+	return s.transition(ctx, notificationID, NotificationStatusInput, NotificationStatusSent)
+}
+
+// TryMarkNotificationError is MarkNotificationError but reports whether the status changed.
+func (s *LibSQL) TryMarkNotificationError(ctx context.Context, notificationID int) (bool, error) {
+	return s.transition(ctx, notificationID, NotificationStatusInput, NotificationStatusError)
+}
+
+// RequeueNotification moves a notification from ERROR back to INPUT so it is delivered
+// again. Like MarkNotificationSent it is a no-op for notifications in any other status.
+func (s *LibSQL) RequeueNotification(ctx context.Context, notificationID int) error {
+	_, err := s.transition(ctx, notificationID, NotificationStatusError, NotificationStatusInput)
+	return err
+}
+
+// RequeueAllErrors moves every notification in ERROR back to INPUT and returns how many
+// were moved. Soft-deleted notifications stay where they are.
+func (s *LibSQL) RequeueAllErrors(ctx context.Context) (int, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"UPDATE notifications SET "+statusUpdate(NotificationStatusInput)+" WHERE status = ? AND deleted_at IS NULL RETURNING notification_id",
+		NotificationStatusInput, NotificationStatusError)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue notifications: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan requeued notification: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("failed to iterate requeued notifications: %w", err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if s.onStatusChange != nil {
+		for _, id := range ids {
+			s.onStatusChange(StatusChange{NotificationID: id, Old: NotificationStatusError, New: NotificationStatusInput})
+		}
+	}
+	return len(ids), nil
+}
+
+// statusUpdate returns the SET clause moving a notification to status, which takes the
+// status as its only argument. Leaving INPUT records the time in sent_at, entering ERROR
+// counts the failure and going back to INPUT clears sent_at again.
+func statusUpdate(status NotificationStatus) string {
+	switch status {
+	case NotificationStatusInput:
+		return "status = ?, sent_at = NULL"
+	case NotificationStatusError:
+		return "status = ?, sent_at = CURRENT_TIMESTAMP, error_count = error_count + 1"
+	default:
+		return "status = ?, sent_at = CURRENT_TIMESTAMP"
+	}
+}
+
+// transition is the single point through which single notification statuses change. It moves the
+// notification from one status to another and emits a StatusChange once committed. Nothing
+// changes if the notification does not exist or is not in the from status.
+func (s *LibSQL) transition(ctx context.Context, notificationID int, from, to NotificationStatus) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
 	result, err := tx.ExecContext(ctx,
-		"UPDATE notifications SET status = ? WHERE notification_id = ? AND status = ?",
-		NotificationStatusSent, notificationID, NotificationStatusInput)
+		"UPDATE notifications SET "+statusUpdate(to)+" WHERE notification_id = ? AND status = ?",
+		to, notificationID, from)
 	if err != nil {
-		return fmt.Errorf("failed to mark notification as sent: %w", err)
+		return false, fmt.Errorf("failed to mark notification as %s: %w", strings.ToLower(string(to)), err)
 	}
 
 	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rows == 0 {
-		return nil
+		return false, nil
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	if s.onStatusChange != nil {
+		s.onStatusChange(StatusChange{NotificationID: notificationID, Old: from, New: to})
+	}
+	return true, nil
 }
 
-func (s *LibSQL) MarkNotificationError(ctx context.Context, notificationID int) error {
+// DeleteNotificationsBefore permanently removes the notifications created before cutoff,
+// only those in one of statuses if any are given, together with their overflow bodies and
+// delivery attempts. Topics and devices are left alone. It returns how many notifications
+// were removed.
+func (s *LibSQL) DeleteNotificationsBefore(ctx context.Context, cutoff time.Time, statuses ...NotificationStatus) (int, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	result, err := tx.ExecContext(ctx,
-		"UPDATE notifications SET status = ? WHERE notification_id = ? AND status = ?",
-		NotificationStatusError, notificationID, NotificationStatusInput)
+	conditions := []string{"timestamp < ?"}
+	args := []any{cutoff.UTC().Format(sqliteTimeFormat)}
+	if len(statuses) > 0 {
+		placeholders := make([]string, 0, len(statuses))
+		for _, status := range statuses {
+			placeholders = append(placeholders, "?")
+			args = append(args, status)
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	selected := "SELECT notification_id FROM notifications WHERE " + strings.Join(conditions, " AND ")
+
+	for _, table := range []string{"notification_bodies", "delivery_attempts"} {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM "+table+" WHERE notification_id IN ("+selected+")", args...); err != nil {
+			return 0, fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM notifications WHERE "+strings.Join(conditions, " AND "), args...)
 	if err != nil {
-		return fmt.Errorf("failed to mark notification as error: %w", err)
+		return 0, fmt.Errorf("failed to delete notifications: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// SoftDeleteNotification hides a notification from queries without removing the row.
+// Deleting an unknown or already deleted notification is a no-op.
+func (s *LibSQL) SoftDeleteNotification(ctx context.Context, notificationID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	if rows == 0 {
-		return nil
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE notifications SET deleted_at = CURRENT_TIMESTAMP WHERE notification_id = ? AND deleted_at IS NULL",
+		notificationID); err != nil {
+		return fmt.Errorf("failed to soft delete notification: %w", err)
 	}
 
 	return tx.Commit()
@@ -2,7 +2,14 @@ package db_test
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dikkadev/cland/internal/db"
 	"github.com/dikkadev/cland/pkg/exchange"
@@ -10,9 +17,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func setupTestDB(t *testing.T) *db.LibSQL {
+func setupTestDB(t *testing.T, opts ...db.Option) *db.LibSQL {
 	// Use in-memory SQLite database
-	database, err := db.NewLibSQL("file::memory:?cache=shared")
+	database, err := db.NewLibSQL("file::memory:?cache=shared", opts...)
 	require.NoError(t, err)
 
 	err = database.Initialize(context.Background())
@@ -77,6 +84,15 @@ func TestTopicCRUD(t *testing.T) {
 		_, err := database.GetOrCreateTopic(ctx, longName, "description")
 		assert.ErrorIs(t, err, db.ErrTopicTooLong)
 	})
+
+	t.Run("multi-byte topic name", func(t *testing.T) {
+		name := strings.Repeat("🚀", db.MaxTopicNameLength)
+		_, err := database.GetOrCreateTopic(ctx, name, "")
+		assert.NoError(t, err, "length is counted in runes, not bytes")
+
+		_, err = database.GetOrCreateTopic(ctx, name+"界", "")
+		assert.ErrorIs(t, err, db.ErrTopicTooLong)
+	})
 }
 
 func TestNotificationCRUD(t *testing.T) {
@@ -279,3 +295,530 @@ func TestSchemaConstraints(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestInfo(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	require.NoError(t, database.InsertDevice(ctx, "device1", "key1"))
+	require.NoError(t, database.InsertDevice(ctx, "device2", "key2"))
+
+	for _, topic := range []string{"info_a", "info_b", "info_a"} {
+		_, err := database.InsertNotification(ctx, exchange.Notification{
+			Topic:   topic,
+			Message: "Test message",
+		})
+		require.NoError(t, err)
+	}
+
+	info, err := database.Info(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, db.SchemaVersion, info.SchemaVersion)
+	assert.NotEmpty(t, info.SQLiteVersion)
+	assert.Equal(t, 3, info.Notifications)
+	assert.Equal(t, 2, info.Topics)
+	assert.Equal(t, 2, info.Devices)
+	assert.Greater(t, info.SizeBytes, int64(0))
+}
+
+func TestDeviceEndpoint(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	t.Run("register device with endpoint", func(t *testing.T) {
+		err := database.RegisterDevice(ctx, db.Device{
+			ID:        "phone",
+			PublicKey: "key1",
+			Endpoint:  "https://push.example.com/phone",
+			Locale:    "de-AT",
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("register device without endpoint", func(t *testing.T) {
+		err := database.InsertDevice(ctx, "laptop", "key2")
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid endpoint", func(t *testing.T) {
+		for _, endpoint := range []string{"push.example.com/x", "ftp://push.example.com", "https://"} {
+			err := database.RegisterDevice(ctx, db.Device{ID: "bad", PublicKey: "key3", Endpoint: endpoint})
+			assert.ErrorIs(t, err, db.ErrInvalidEndpoint, endpoint)
+		}
+	})
+
+	t.Run("list devices", func(t *testing.T) {
+		devices, err := database.ListDevices(ctx)
+		require.NoError(t, err)
+		require.Len(t, devices, 2)
+
+		assert.Equal(t, "laptop", devices[0].ID)
+		assert.Empty(t, devices[0].Endpoint)
+		assert.Empty(t, devices[0].Locale)
+		assert.Equal(t, "phone", devices[1].ID)
+		assert.Equal(t, "key1", devices[1].PublicKey)
+		assert.Equal(t, "https://push.example.com/phone", devices[1].Endpoint)
+		assert.Equal(t, "de-AT", devices[1].Locale)
+		assert.False(t, devices[1].RegisteredAt.IsZero())
+	})
+}
+
+func TestStatusChangeHook(t *testing.T) {
+	ctx := context.Background()
+
+	var changes []db.StatusChange
+	database := setupTestDB(t, db.WithStatusChangeHook(func(c db.StatusChange) {
+		changes = append(changes, c)
+	}))
+	defer database.Close()
+
+	notif := exchange.Notification{Topic: "hook", Message: "Test message"}
+	sentID, err := database.InsertNotification(ctx, notif)
+	require.NoError(t, err)
+	errorID, err := database.InsertNotification(ctx, notif)
+	require.NoError(t, err)
+
+	require.NoError(t, database.MarkNotificationSent(ctx, sentID))
+	require.Equal(t, []db.StatusChange{
+		{NotificationID: sentID, Old: db.NotificationStatusInput, New: db.NotificationStatusSent},
+	}, changes)
+
+	// No-op transitions must not emit events.
+	require.NoError(t, database.MarkNotificationSent(ctx, sentID))
+	require.NoError(t, database.MarkNotificationError(ctx, sentID))
+	require.NoError(t, database.MarkNotificationSent(ctx, 99999))
+	require.Len(t, changes, 1)
+
+	require.NoError(t, database.MarkNotificationError(ctx, errorID))
+	assert.Equal(t, db.StatusChange{
+		NotificationID: errorID, Old: db.NotificationStatusInput, New: db.NotificationStatusError,
+	}, changes[1])
+
+	n, err := database.RequeueAllErrors(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	assert.Equal(t, db.StatusChange{
+		NotificationID: errorID, Old: db.NotificationStatusError, New: db.NotificationStatusInput,
+	}, changes[2])
+}
+
+func TestTopicDescriptionPolicy(t *testing.T) {
+	ctx := context.Background()
+	notif := exchange.Notification{
+		Topic:    "described",
+		Message:  "hello",
+		Metadata: map[string]string{db.DescriptionMetadataKey: "updated"},
+	}
+
+	tests := []struct {
+		name   string
+		policy db.TopicDescriptionPolicy
+		prior  string
+		want   string
+	}{
+		{"ignore keeps prior", db.DescriptionIgnore, "prior", "prior"},
+		{"ignore leaves empty", db.DescriptionIgnore, "", ""},
+		{"set if empty keeps prior", db.DescriptionSetIfEmpty, "prior", "prior"},
+		{"set if empty fills empty", db.DescriptionSetIfEmpty, "", "updated"},
+		{"last writer wins overwrites", db.DescriptionLastWriterWins, "prior", "updated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := setupTestDB(t, db.WithTopicDescriptionPolicy(tt.policy))
+			defer database.Close()
+
+			_, err := database.GetOrCreateTopic(ctx, notif.Topic, tt.prior)
+			require.NoError(t, err)
+			_, err = database.InsertNotification(ctx, notif)
+			require.NoError(t, err)
+
+			description, err := database.TopicDescription(ctx, notif.Topic)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, description)
+		})
+	}
+
+	t.Run("missing description never clears", func(t *testing.T) {
+		database := setupTestDB(t, db.WithTopicDescriptionPolicy(db.DescriptionLastWriterWins))
+		defer database.Close()
+
+		_, err := database.GetOrCreateTopic(ctx, notif.Topic, "prior")
+		require.NoError(t, err)
+		_, err = database.InsertNotification(ctx, exchange.Notification{Topic: notif.Topic, Message: "no description"})
+		require.NoError(t, err)
+
+		description, err := database.TopicDescription(ctx, notif.Topic)
+		require.NoError(t, err)
+		assert.Equal(t, "prior", description)
+	})
+}
+
+func TestTopicPattern(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t, db.WithTopicPattern(regexp.MustCompile(`^[a-z0-9]+(/[a-z0-9]+)*$`)))
+	defer database.Close()
+
+	t.Run("conforming topic", func(t *testing.T) {
+		_, err := database.InsertNotification(ctx, exchange.Notification{Topic: "infra/backups", Message: "done"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("non-conforming topic", func(t *testing.T) {
+		for _, topic := range []string{"Infra/Backups", "infra backups", "infra//backups"} {
+			_, err := database.InsertNotification(ctx, exchange.Notification{Topic: topic, Message: "done"})
+			assert.ErrorIs(t, err, db.ErrInvalidTopicName, topic)
+			_, err = database.GetOrCreateTopic(ctx, topic, "")
+			assert.ErrorIs(t, err, db.ErrInvalidTopicName, topic)
+		}
+	})
+
+	t.Run("no pattern keeps accepting everything", func(t *testing.T) {
+		plain := setupTestDB(t)
+		defer plain.Close()
+		_, err := plain.InsertNotification(ctx, exchange.Notification{Topic: "Infra Backups", Message: "done"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestInitializeIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	_, err := database.InsertNotification(ctx, exchange.Notification{Topic: "migrations", Message: "kept"})
+	require.NoError(t, err)
+
+	require.NoError(t, database.Initialize(ctx))
+	require.NoError(t, database.Initialize(ctx))
+
+	info, err := database.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, db.SchemaVersion, info.SchemaVersion)
+	assert.Equal(t, 1, info.Notifications)
+}
+
+// baseTables is the schema as created before any versioning, databases of that time have
+// user_version 0 and none of the later columns.
+const baseTables = `
+CREATE TABLE IF NOT EXISTS devices (
+	device_id TEXT PRIMARY KEY,
+	public_key TEXT NOT NULL,
+	registration_date DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS topics (
+	topic_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic_name TEXT NOT NULL UNIQUE,
+	description TEXT,
+	creation_date DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS notifications (
+	notification_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic_id INTEGER NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	message TEXT NOT NULL,
+	metadata TEXT,
+	status TEXT CHECK(status IN ('INPUT', 'SENT', 'ERROR')) DEFAULT 'INPUT',
+	FOREIGN KEY(topic_id) REFERENCES topics(topic_id)
+);
+`
+
+func TestInitializeUpgradesExistingDatabase(t *testing.T) {
+	ctx := context.Background()
+	url := "file:" + filepath.Join(t.TempDir(), "old.db")
+
+	raw, err := sql.Open("libsql", url)
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, baseTables)
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, "INSERT INTO devices (device_id, public_key) VALUES ('phone', 'key')")
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, "INSERT INTO topics (topic_name, description) VALUES ('old', 'from before')")
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, `INSERT INTO notifications (topic_id, message, metadata, status)
+		VALUES (1, 'before upgrade', '{"host":"a"}', 'SENT')`)
+	require.NoError(t, err)
+	require.NoError(t, raw.Close())
+
+	database, err := db.NewLibSQL(url)
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, database.Initialize(ctx))
+
+	info, err := database.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, db.SchemaVersion, info.SchemaVersion)
+	assert.Equal(t, 1, info.Devices)
+	assert.Equal(t, 1, info.Topics)
+	assert.Equal(t, 1, info.Notifications)
+
+	devices, err := database.ListDevices(ctx)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "phone", devices[0].ID)
+	assert.Equal(t, "key", devices[0].PublicKey)
+
+	description, err := database.TopicDescription(ctx, "old")
+	require.NoError(t, err)
+	assert.Equal(t, "from before", description)
+
+	old, err := database.GetNotification(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "before upgrade", old.Message)
+	assert.Equal(t, map[string]string{"host": "a"}, old.Metadata)
+	assert.Equal(t, db.NotificationStatusSent, old.Status)
+	assert.Equal(t, 0, old.Priority)
+	assert.Equal(t, 0, old.ErrorCount)
+	assert.Nil(t, old.SentAt)
+
+	// Every later column and table is usable on the upgraded database.
+	notif := exchange.Notification{Topic: "old", Message: "after upgrade", Priority: 3, IdempotencyKey: "k"}
+	id, err := database.InsertNotification(ctx, notif)
+	require.NoError(t, err)
+	require.NoError(t, database.Subscribe(ctx, "phone", "old"))
+	require.NoError(t, database.MarkNotificationError(ctx, id))
+
+	found, err := database.FindByFingerprint(ctx, notif.Fingerprint())
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, id, found[0].ID)
+	assert.Equal(t, 3, found[0].Priority)
+	assert.Equal(t, 1, found[0].ErrorCount)
+}
+
+func TestInitializeRollsBackFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	url := "file:" + filepath.Join(t.TempDir(), "conflict.db")
+
+	// An old database someone already added content_hash to by hand, which makes the
+	// content hash migration fail after the ones before it succeeded.
+	raw, err := sql.Open("libsql", url)
+	require.NoError(t, err)
+	defer raw.Close()
+	_, err = raw.ExecContext(ctx, baseTables)
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, "ALTER TABLE notifications ADD COLUMN content_hash TEXT")
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, "INSERT INTO topics (topic_name) VALUES ('old')")
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, "INSERT INTO notifications (topic_id, message) VALUES (1, 'before upgrade')")
+	require.NoError(t, err)
+
+	database, err := db.NewLibSQL(url)
+	require.NoError(t, err)
+	defer database.Close()
+
+	err = database.Initialize(ctx)
+	var migrationErr *db.MigrationError
+	require.ErrorAs(t, err, &migrationErr)
+	assert.Equal(t, 13, migrationErr.Version)
+
+	// Nothing of the failed run is left, not even the steps before the failing one.
+	var columns int
+	require.NoError(t, raw.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM pragma_table_info('notifications') WHERE name IN ('fingerprint', 'priority')").Scan(&columns))
+	assert.Zero(t, columns, "columns of migrations 3 and 10")
+	var tables int
+	require.NoError(t, raw.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('schema_migrations', 'delivery_attempts', 'subscriptions')").Scan(&tables))
+	assert.Zero(t, tables)
+	var version int
+	require.NoError(t, raw.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version))
+	assert.Zero(t, version)
+
+	// Once the conflict is resolved the next Initialize applies everything.
+	_, err = raw.ExecContext(ctx, "ALTER TABLE notifications DROP COLUMN content_hash")
+	require.NoError(t, err)
+	require.NoError(t, database.Initialize(ctx))
+
+	stored, err := database.ListNotifications(ctx, db.NotificationFilter{Topic: "old"})
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "before upgrade", stored[0].Message)
+
+	info, err := database.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, db.SchemaVersion, info.SchemaVersion)
+}
+
+func TestInitializeIgnoresUserVersion(t *testing.T) {
+	ctx := context.Background()
+	url := "file:" + filepath.Join(t.TempDir(), "foreign.db")
+
+	// user_version set by something other than cland says nothing about which steps ran.
+	raw, err := sql.Open("libsql", url)
+	require.NoError(t, err)
+	defer raw.Close()
+	_, err = raw.ExecContext(ctx, baseTables)
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, "PRAGMA user_version = 8")
+	require.NoError(t, err)
+
+	database, err := db.NewLibSQL(url)
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, database.Initialize(ctx))
+
+	var columns int
+	require.NoError(t, raw.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM pragma_table_info('notifications') WHERE name IN ('fingerprint', 'priority')").Scan(&columns))
+	assert.Equal(t, 2, columns, "columns of migrations 3 and 10")
+
+	info, err := database.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, db.SchemaVersion, info.SchemaVersion)
+}
+
+func TestSizeLimits(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t, db.WithMaxMessageLength(10), db.WithMaxMetadataBytes(20))
+	defer database.Close()
+
+	insert := func(message string, metadata map[string]string) error {
+		_, err := database.InsertNotification(ctx, exchange.Notification{Topic: "limits", Message: message, Metadata: metadata})
+		return err
+	}
+
+	assert.NoError(t, insert(strings.Repeat("x", 10), nil))
+	assert.ErrorIs(t, insert(strings.Repeat("x", 11), nil), db.ErrMessageTooLong)
+	assert.ErrorIs(t, insert(strings.Repeat("x", 11), nil), exchange.ErrInvalidNotification, "not retried by the handler")
+	assert.ErrorIs(t, insert("", nil), exchange.ErrInvalidNotification)
+
+	// {"key":"..."} is 10 bytes plus the value.
+	assert.NoError(t, insert("ok", map[string]string{"key": strings.Repeat("v", 10)}))
+	assert.ErrorIs(t, insert("ok", map[string]string{"key": strings.Repeat("v", 11)}), db.ErrMetadataTooLarge)
+
+	unlimited := setupTestDB(t, db.WithMaxMessageLength(0), db.WithMaxMetadataBytes(0))
+	defer unlimited.Close()
+	_, err := unlimited.InsertNotification(ctx, exchange.Notification{Topic: "limits", Message: strings.Repeat("x", db.DefaultMaxMessageLength+1)})
+	assert.NoError(t, err)
+}
+
+func TestDeleteNotificationsBefore(t *testing.T) {
+	ctx := context.Background()
+	url := "file:" + filepath.Join(t.TempDir(), "retention.db")
+	database, err := db.NewLibSQL(url, db.WithBodyOverflowThreshold(16))
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, database.Initialize(ctx))
+	raw, err := sql.Open("libsql", url)
+	require.NoError(t, err)
+	defer raw.Close()
+
+	insert := func(message string, age time.Duration) int {
+		id, err := database.InsertNotification(ctx, exchange.Notification{Topic: "retention", Message: message})
+		require.NoError(t, err)
+		if age > 0 {
+			_, err = raw.ExecContext(ctx, "UPDATE notifications SET timestamp = ? WHERE notification_id = ?",
+				time.Now().Add(-age).UTC().Format("2006-01-02 15:04:05"), id)
+			require.NoError(t, err)
+		}
+		return id
+	}
+	oldSent := insert("old sent with a body long enough to overflow", 48*time.Hour)
+	oldFailed := insert("old failed", 48*time.Hour)
+	recentSent := insert("recent sent", 0)
+	require.NoError(t, database.MarkNotificationSent(ctx, oldSent))
+	require.NoError(t, database.MarkNotificationError(ctx, oldFailed))
+	require.NoError(t, database.MarkNotificationSent(ctx, recentSent))
+	require.NoError(t, database.InsertDevice(ctx, "device", "key"))
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	n, err := database.DeleteNotificationsBefore(ctx, cutoff, db.NotificationStatusSent)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "only the old SENT notification")
+
+	_, err = database.GetNotification(ctx, oldSent)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = database.GetNotification(ctx, oldFailed)
+	assert.NoError(t, err)
+	_, err = database.GetNotification(ctx, recentSent)
+	assert.NoError(t, err)
+
+	n, err = database.DeleteNotificationsBefore(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "the old ERROR notification, without a status filter")
+
+	info, err := database.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, info.Notifications)
+	assert.Equal(t, 0, info.OverflowBodies)
+	assert.Equal(t, 1, info.Topics)
+	assert.Equal(t, 1, info.Devices)
+}
+
+func TestDedupWindow(t *testing.T) {
+	ctx := context.Background()
+	url := "file:" + filepath.Join(t.TempDir(), "dedup.db")
+	database, err := db.NewLibSQL(url, db.WithDedupWindow(time.Hour))
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, database.Initialize(ctx))
+	raw, err := sql.Open("libsql", url)
+	require.NoError(t, err)
+	defer raw.Close()
+
+	notif := exchange.Notification{Topic: "dedup", Message: "message", ContentHash: "hash"}
+	id, err := database.InsertNotification(ctx, notif)
+	require.NoError(t, err)
+	_, err = database.InsertNotification(ctx, notif)
+	assert.ErrorIs(t, err, db.ErrDuplicateNotification)
+
+	_, err = raw.ExecContext(ctx, "UPDATE notifications SET timestamp = ? WHERE notification_id = ?",
+		time.Now().Add(-2*time.Hour).UTC().Format("2006-01-02 15:04:05"), id)
+	require.NoError(t, err)
+	_, err = database.InsertNotification(ctx, notif)
+	assert.NoError(t, err, "notification outside the window")
+
+	disabled := setupTestDB(t)
+	defer disabled.Close()
+	notif.ContentHash = "disabled-hash"
+	for range 2 {
+		_, err = disabled.InsertNotification(ctx, notif)
+		assert.NoError(t, err)
+	}
+}
+
+func TestIdempotencyKeyConcurrentInserts(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+	// Only the notification inserts should race, not the creation of their topic.
+	_, err := database.GetOrCreateTopic(ctx, "concurrent_idempotency", "")
+	require.NoError(t, err)
+
+	const n = 10
+	ids := make([]int, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], errs[i] = database.InsertNotification(ctx, exchange.Notification{
+				Topic:          "concurrent_idempotency",
+				Message:        fmt.Sprintf("attempt %d", i),
+				IdempotencyKey: "same-key",
+			})
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for i := range n {
+		if errs[i] == nil {
+			created++
+		} else {
+			require.ErrorIs(t, errs[i], db.ErrDuplicateNotification)
+		}
+		assert.Equal(t, ids[0], ids[i])
+	}
+	assert.Equal(t, 1, created)
+	assert.NotZero(t, ids[0])
+
+	count, err := database.CountNotifications(ctx, db.NotificationFilter{Topic: "concurrent_idempotency"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
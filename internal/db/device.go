@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Device struct {
+	ID        string
+	PublicKey string
+	// SigningKey is the base64 Ed25519 key the device signs notification files with. It is
+	// optional and separate from PublicKey, which push encryption uses.
+	SigningKey   string
+	Endpoint     string
+	Locale       string
+	RegisteredAt time.Time
+}
+
+// GetDevicePublicKey returns the public key deviceID registered with. The error wraps
+// sql.ErrNoRows if there is no such device.
+func (s *LibSQL) GetDevicePublicKey(ctx context.Context, deviceID string) (string, error) {
+	var publicKey string
+	err := s.db.QueryRowContext(ctx, "SELECT public_key FROM devices WHERE device_id = ?", deviceID).Scan(&publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get public key of device %s: %w", deviceID, err)
+	}
+	return publicKey, nil
+}
+
+// GetDeviceSigningKey returns the signing key deviceID registered with. The error wraps
+// sql.ErrNoRows if there is no such device or it has no signing key.
+func (s *LibSQL) GetDeviceSigningKey(ctx context.Context, deviceID string) (string, error) {
+	var signingKey sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT signing_key FROM devices WHERE device_id = ?", deviceID).Scan(&signingKey)
+	if err == nil && !signingKey.Valid {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get signing key of device %s: %w", deviceID, err)
+	}
+	return signingKey.String, nil
+}
+
+func (s *LibSQL) ListDevices(ctx context.Context) ([]Device, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT device_id, public_key, COALESCE(signing_key, ''), COALESCE(endpoint, ''), COALESCE(locale, ''), registration_date
+		FROM devices ORDER BY device_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query devices: %w", err)
+	}
+	return scanDevices(rows)
+}
+
+func scanDevices(rows *sql.Rows) ([]Device, error) {
+	defer rows.Close()
+
+	devices := make([]Device, 0)
+	for rows.Next() {
+		var d Device
+		if err := rows.Scan(&d.ID, &d.PublicKey, &d.SigningKey, &d.Endpoint, &d.Locale, &d.RegisteredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices = append(devices, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate devices: %w", err)
+	}
+	return devices, nil
+}
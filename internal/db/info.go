@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+type DBInfo struct {
+	SchemaVersion  int
+	SQLiteVersion  string
+	Notifications  int
+	Topics         int
+	Devices        int
+	OverflowBodies int
+	SizeBytes      int64
+}
+
+func (s *LibSQL) Info(ctx context.Context) (DBInfo, error) {
+	var info DBInfo
+
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&info.SchemaVersion); err != nil {
+		return DBInfo{}, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	if err := s.db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&info.SQLiteVersion); err != nil {
+		return DBInfo{}, fmt.Errorf("failed to get sqlite version: %w", err)
+	}
+
+	err := s.db.QueryRowContext(ctx, `SELECT
+		(SELECT COUNT(*) FROM notifications WHERE deleted_at IS NULL),
+		(SELECT COUNT(*) FROM topics),
+		(SELECT COUNT(*) FROM devices),
+		(SELECT COUNT(*) FROM notification_bodies)`).Scan(&info.Notifications, &info.Topics, &info.Devices, &info.OverflowBodies)
+	if err != nil {
+		return DBInfo{}, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	// Only meaningful for embedded databases, remote ones report their own page layout.
+	err = s.db.QueryRowContext(ctx,
+		"SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&info.SizeBytes)
+	if err != nil {
+		return DBInfo{}, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+const iteratePageSize = 100
+
+// IterateNotifications calls fn for every notification, soft-deleted ones included, in
+// primary key order. All pages are read inside one transaction so fn sees a consistent
+// snapshot without the whole table being loaded into memory, overflow bodies are not loaded
+// either, see BodyOmitted. Iteration stops at the first error returned by fn.
+func (s *LibSQL) IterateNotifications(ctx context.Context, fn func(StoredNotification) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	lastID := 0
+	for {
+		page, err := queryNotifications(ctx, tx, selectStoredNotifications, NotificationFilter{IncludeDeleted: true},
+			[]string{"n.notification_id > ?"}, []any{lastID}, iteratePageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, notif := range page {
+			if err := fn(notif); err != nil {
+				return err
+			}
+			lastID = notif.ID
+		}
+
+		if len(page) < iteratePageSize {
+			return tx.Commit()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterateNotifications(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	const total = 345
+	inserted := make(map[int]string, total)
+	for i := 0; i < total; i++ {
+		msg := fmt.Sprintf("message %d", i)
+		id, err := database.InsertNotification(ctx, exchange.Notification{Topic: "iterate", Message: msg})
+		require.NoError(t, err)
+		inserted[id] = msg
+	}
+	var deletedID int
+	for id := range inserted {
+		deletedID = id
+		break
+	}
+	require.NoError(t, database.SoftDeleteNotification(ctx, deletedID))
+
+	t.Run("every row exactly once", func(t *testing.T) {
+		seen := make(map[int]int, total)
+		lastID := 0
+		err := database.IterateNotifications(ctx, func(notif db.StoredNotification) error {
+			seen[notif.ID]++
+			assert.Greater(t, notif.ID, lastID)
+			lastID = notif.ID
+			assert.Equal(t, inserted[notif.ID], notif.Message)
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Len(t, seen, total)
+		for id, n := range seen {
+			assert.Equal(t, 1, n, "notification %d", id)
+		}
+	})
+
+	t.Run("stops on callback error", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		err := database.IterateNotifications(ctx, func(db.StoredNotification) error {
+			calls++
+			if calls == 150 {
+				return stop
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 150, calls)
+	})
+}
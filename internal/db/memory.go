@@ -0,0 +1,551 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dikkadev/cland/pkg/exchange"
+)
+
+var ErrClosed = errors.New("store is closed")
+
+// Memory is an in-process Store. It follows the same validation and status rules as
+// LibSQL but keeps nothing across restarts.
+type Memory struct {
+	mu            sync.Mutex
+	closed        bool
+	devices       map[string]Device
+	topics        map[string]int
+	subscriptions map[string]map[string]bool
+	notifications []StoredNotification
+	now           func() time.Time
+	dedupWindow   time.Duration
+}
+
+type MemoryOption func(*Memory)
+
+// WithMemoryDedupWindow is WithDedupWindow for Memory.
+func WithMemoryDedupWindow(window time.Duration) MemoryOption {
+	return func(m *Memory) {
+		m.dedupWindow = window
+	}
+}
+
+func NewMemory(opts ...MemoryOption) *Memory {
+	m := &Memory{
+		devices:       make(map[string]Device),
+		topics:        make(map[string]int),
+		subscriptions: make(map[string]map[string]bool),
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+// timestamp mirrors the second precision of CURRENT_TIMESTAMP.
+func (m *Memory) timestamp() time.Time {
+	return m.now().UTC().Truncate(time.Second)
+}
+
+func (m *Memory) InsertDevice(ctx context.Context, deviceID, publicKey string) error {
+	return m.RegisterDevice(ctx, Device{ID: deviceID, PublicKey: publicKey})
+}
+
+func (m *Memory) RegisterDevice(ctx context.Context, device Device) error {
+	if err := validateDevice(device.ID, device.PublicKey); err != nil {
+		return err
+	}
+	if err := validateEndpoint(device.Endpoint); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if _, ok := m.devices[device.ID]; ok {
+		return fmt.Errorf("failed to insert device: device %s already exists", device.ID)
+	}
+	device.RegisteredAt = m.timestamp()
+	m.devices[device.ID] = device
+	return nil
+}
+
+func (m *Memory) GetDevicePublicKey(ctx context.Context, deviceID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return "", ErrClosed
+	}
+
+	device, ok := m.devices[deviceID]
+	if !ok {
+		return "", fmt.Errorf("failed to get public key of device %s: %w", deviceID, sql.ErrNoRows)
+	}
+	return device.PublicKey, nil
+}
+
+func (m *Memory) GetDeviceSigningKey(ctx context.Context, deviceID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return "", ErrClosed
+	}
+
+	device, ok := m.devices[deviceID]
+	if !ok || device.SigningKey == "" {
+		return "", fmt.Errorf("failed to get signing key of device %s: %w", deviceID, sql.ErrNoRows)
+	}
+	return device.SigningKey, nil
+}
+
+func (m *Memory) ListDevices(ctx context.Context) ([]Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	devices := make([]Device, 0, len(m.devices))
+	for _, d := range m.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	return devices, nil
+}
+
+func (m *Memory) GetOrCreateTopic(ctx context.Context, topicName string, description string) (int, error) {
+	if err := validateTopic(topicName, nil); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+	return m.topicID(topicName), nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, deviceID, topicName string) error {
+	if err := validateTopic(topicName, nil); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if _, ok := m.devices[deviceID]; !ok {
+		return fmt.Errorf("failed to subscribe device %s: %w", deviceID, ErrUnknownDevice)
+	}
+	m.topicID(topicName)
+	if m.subscriptions[topicName] == nil {
+		m.subscriptions[topicName] = make(map[string]bool)
+	}
+	m.subscriptions[topicName][deviceID] = true
+	return nil
+}
+
+func (m *Memory) Unsubscribe(ctx context.Context, deviceID, topicName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	delete(m.subscriptions[topicName], deviceID)
+	return nil
+}
+
+func (m *Memory) DevicesForTopic(ctx context.Context, topicName string) ([]Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	devices := make([]Device, 0, len(m.subscriptions[topicName]))
+	for id := range m.subscriptions[topicName] {
+		devices = append(devices, m.devices[id])
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	return devices, nil
+}
+
+// topicID must be called with m.mu held.
+func (m *Memory) topicID(topicName string) int {
+	id, ok := m.topics[topicName]
+	if !ok {
+		id = len(m.topics) + 1
+		m.topics[topicName] = id
+	}
+	return id
+}
+
+func (m *Memory) ValidateNotification(notif exchange.Notification) error {
+	return validateNotification(notif, nil, DefaultMaxMessageLength, DefaultMaxMetadataBytes)
+}
+
+func (m *Memory) InsertNotification(ctx context.Context, notif exchange.Notification) (int, error) {
+	if err := m.ValidateNotification(notif); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+
+	if notif.IdempotencyKey != "" {
+		for _, n := range m.notifications {
+			if n.ID != 0 && n.IdempotencyKey == notif.IdempotencyKey {
+				return n.ID, fmt.Errorf("notification %d has idempotency key %q: %w", n.ID, notif.IdempotencyKey, ErrDuplicateNotification)
+			}
+		}
+	}
+	if m.duplicate(notif.ContentHash) {
+		return 0, ErrDuplicateNotification
+	}
+
+	m.topicID(notif.Topic)
+	metadata := make(map[string]string, len(notif.Metadata))
+	for k, v := range notif.Metadata {
+		metadata[k] = v
+	}
+	var sendAt *time.Time
+	if !notif.SendAt.IsZero() {
+		t := notif.SendAt.UTC().Truncate(time.Second)
+		sendAt = &t
+	}
+	id := len(m.notifications) + 1
+	m.notifications = append(m.notifications, StoredNotification{
+		ID:             id,
+		Topic:          notif.Topic,
+		Timestamp:      m.timestamp(),
+		Status:         NotificationStatusInput,
+		Priority:       notif.Priority,
+		SendAt:         sendAt,
+		Message:        notif.Message,
+		Metadata:       metadata,
+		Fingerprint:    notif.Fingerprint(),
+		ContentHash:    notif.ContentHash,
+		IdempotencyKey: notif.IdempotencyKey,
+	})
+	return id, nil
+}
+
+// duplicate reports whether a notification with contentHash was stored within the dedup
+// window. It must be called with m.mu held.
+func (m *Memory) duplicate(contentHash string) bool {
+	if contentHash == "" || m.dedupWindow <= 0 {
+		return false
+	}
+	since := m.timestamp().Add(-m.dedupWindow)
+	for _, n := range m.notifications {
+		if n.ID != 0 && n.DeletedAt == nil && n.ContentHash == contentHash && !n.Timestamp.Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// matching must be called with m.mu held.
+func (m *Memory) matching(filter NotificationFilter, extra func(StoredNotification) bool) []StoredNotification {
+	notifs := make([]StoredNotification, 0)
+	for _, n := range m.notifications {
+		if n.ID == 0 {
+			continue
+		}
+		if filter.Topic != "" && n.Topic != filter.Topic {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, n.Status) {
+			continue
+		}
+		if !filter.Since.IsZero() && n.Timestamp.Before(filter.Since.UTC().Truncate(time.Second)) {
+			continue
+		}
+		if !filter.Until.IsZero() && n.Timestamp.After(filter.Until.UTC()) {
+			continue
+		}
+		if !filter.IncludeDeleted && n.DeletedAt != nil {
+			continue
+		}
+		if extra != nil && !extra(n) {
+			continue
+		}
+		notifs = append(notifs, n)
+	}
+	return notifs
+}
+
+func (m *Memory) ListNotifications(ctx context.Context, filter NotificationFilter) ([]StoredNotification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	notifs := m.matching(filter, nil)
+	sort.SliceStable(notifs, func(i, j int) bool {
+		if !notifs[i].Timestamp.Equal(notifs[j].Timestamp) {
+			return notifs[i].Timestamp.After(notifs[j].Timestamp)
+		}
+		return notifs[i].ID > notifs[j].ID
+	})
+	if filter.Offset >= len(notifs) {
+		return []StoredNotification{}, nil
+	}
+	notifs = notifs[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(notifs) {
+		notifs = notifs[:filter.Limit]
+	}
+	return notifs, nil
+}
+
+func (m *Memory) GetNotification(ctx context.Context, notificationID int) (StoredNotification, error) {
+	return m.getNotification(notificationID, false)
+}
+
+func (m *Memory) GetNotificationIncludingDeleted(ctx context.Context, notificationID int) (StoredNotification, error) {
+	return m.getNotification(notificationID, true)
+}
+
+func (m *Memory) getNotification(notificationID int, includeDeleted bool) (StoredNotification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return StoredNotification{}, ErrClosed
+	}
+
+	n := m.notification(notificationID)
+	if n == nil || (!includeDeleted && n.DeletedAt != nil) {
+		return StoredNotification{}, fmt.Errorf("failed to get notification %d: %w", notificationID, sql.ErrNoRows)
+	}
+	return *n, nil
+}
+
+func (m *Memory) GetPendingNotifications(ctx context.Context, limit int) ([]StoredNotification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	pending := m.matching(NotificationFilter{Statuses: []NotificationStatus{NotificationStatusInput}}, nil)
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Priority > pending[j].Priority
+	})
+	if limit > 0 && limit < len(pending) {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (m *Memory) GetDueNotifications(ctx context.Context, now time.Time, limit int) ([]StoredNotification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	due := m.matching(NotificationFilter{Statuses: []NotificationStatus{NotificationStatusInput}}, func(n StoredNotification) bool {
+		return n.SendAt == nil || !n.SendAt.After(now)
+	})
+	if limit > 0 && limit < len(due) {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (m *Memory) CountNotifications(ctx context.Context, filter NotificationFilter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+	return len(m.matching(filter, nil)), nil
+}
+
+func (m *Memory) CountByStatus(ctx context.Context) (map[NotificationStatus]int, error) {
+	return m.countByStatus(NotificationFilter{})
+}
+
+func (m *Memory) CountByTopic(ctx context.Context, topicName string) (map[NotificationStatus]int, error) {
+	return m.countByStatus(NotificationFilter{Topic: topicName})
+}
+
+func (m *Memory) countByStatus(filter NotificationFilter) (map[NotificationStatus]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	counts := newStatusCounts()
+	for _, n := range m.matching(filter, nil) {
+		counts[n.Status]++
+	}
+	return counts, nil
+}
+
+func (m *Memory) FindByFingerprint(ctx context.Context, fingerprint string) ([]StoredNotification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	return m.matching(NotificationFilter{}, func(n StoredNotification) bool {
+		return n.Fingerprint == fingerprint
+	}), nil
+}
+
+// IterateNotifications works on a copy taken up front, which gives fn the same
+// consistent snapshot LibSQL provides.
+func (m *Memory) IterateNotifications(ctx context.Context, fn func(StoredNotification) error) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+	snapshot := m.matching(NotificationFilter{IncludeDeleted: true}, nil)
+	m.mu.Unlock()
+
+	for _, n := range snapshot {
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteNotificationsBefore leaves an empty slot for every removed notification, so IDs
+// keep matching slice positions and are never reused.
+func (m *Memory) DeleteNotificationsBefore(ctx context.Context, cutoff time.Time, statuses ...NotificationStatus) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+
+	deleted := 0
+	for i := range m.notifications {
+		n := &m.notifications[i]
+		if n.ID == 0 || !n.Timestamp.Before(cutoff.UTC().Truncate(time.Second)) {
+			continue
+		}
+		if len(statuses) > 0 && !slices.Contains(statuses, n.Status) {
+			continue
+		}
+		m.notifications[i] = StoredNotification{}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (m *Memory) SoftDeleteNotification(ctx context.Context, notificationID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+
+	n := m.notification(notificationID)
+	if n == nil || n.DeletedAt != nil {
+		return nil
+	}
+	deletedAt := m.timestamp()
+	n.DeletedAt = &deletedAt
+	return nil
+}
+
+func (m *Memory) MarkNotificationSent(ctx context.Context, notificationID int) error {
+	_, err := m.TryMarkNotificationSent(ctx, notificationID)
+	return err
+}
+
+func (m *Memory) MarkNotificationError(ctx context.Context, notificationID int) error {
+	_, err := m.TryMarkNotificationError(ctx, notificationID)
+	return err
+}
+
+func (m *Memory) TryMarkNotificationSent(ctx context.Context, notificationID int) (bool, error) {
+	return m.transition(notificationID, NotificationStatusInput, NotificationStatusSent)
+}
+
+func (m *Memory) TryMarkNotificationError(ctx context.Context, notificationID int) (bool, error) {
+	return m.transition(notificationID, NotificationStatusInput, NotificationStatusError)
+}
+
+func (m *Memory) RequeueNotification(ctx context.Context, notificationID int) error {
+	_, err := m.transition(notificationID, NotificationStatusError, NotificationStatusInput)
+	return err
+}
+
+func (m *Memory) RequeueAllErrors(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+
+	requeued := 0
+	for i := range m.notifications {
+		n := &m.notifications[i]
+		if n.Status == NotificationStatusError && n.DeletedAt == nil {
+			n.Status = NotificationStatusInput
+			n.SentAt = nil
+			requeued++
+		}
+	}
+	return requeued, nil
+}
+
+func (m *Memory) transition(notificationID int, from, to NotificationStatus) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return false, ErrClosed
+	}
+
+	n := m.notification(notificationID)
+	if n == nil || n.Status != from {
+		return false, nil
+	}
+	n.Status = to
+	n.SentAt = nil
+	if to != NotificationStatusInput {
+		sentAt := m.timestamp()
+		n.SentAt = &sentAt
+	}
+	if to == NotificationStatusError {
+		n.ErrorCount++
+	}
+	return true, nil
+}
+
+// notification must be called with m.mu held.
+func (m *Memory) notification(id int) *StoredNotification {
+	if id < 1 || id > len(m.notifications) || m.notifications[id-1].ID == 0 {
+		return nil
+	}
+	return &m.notifications[id-1]
+}
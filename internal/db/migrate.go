@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// MigrationError reports the migration step that failed. Initialize applies all pending
+// steps in one transaction, so none of them is left behind.
+type MigrationError struct {
+	Version int
+	Name    string
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("failed to apply migration %d (%s): %v", e.Version, e.Name, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// migrate applies the steps newer than the last recorded version in a single transaction,
+// so a failing step leaves the database exactly as it was.
+func (s *LibSQL) migrate(ctx context.Context, steps []migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, CREATE_SCHEMA_MIGRATIONS_TABLE); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var current int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("failed to get applied schema version: %w", err)
+	}
+
+	applied := current
+	for _, m := range steps {
+		if m.version <= current {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.up); err != nil {
+			return &MigrationError{Version: m.version, Name: m.name, Err: err}
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", m.version); err != nil {
+			return &MigrationError{Version: m.version, Name: m.name, Err: fmt.Errorf("failed to record migration: %w", err)}
+		}
+		applied = m.version
+	}
+	if applied == current {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", applied)); err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+
+	return tx.Commit()
+}
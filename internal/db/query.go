@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sqliteTimeFormat matches the layout CURRENT_TIMESTAMP writes, so comparisons stay lexical.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+type NotificationFilter struct {
+	Topic string
+	// Statuses matches any of the given statuses, empty matches all.
+	Statuses []NotificationStatus
+	Since    time.Time
+	Until    time.Time
+	// IncludeDeleted also returns soft-deleted notifications.
+	IncludeDeleted bool
+
+	// Limit and Offset page through ListNotifications, a Limit of zero returns everything.
+	// Other queries ignore them.
+	Limit  int
+	Offset int
+}
+
+type StoredNotification struct {
+	ID             int
+	Topic          string
+	Timestamp      time.Time
+	Status         NotificationStatus
+	Priority       int
+	SendAt         *time.Time
+	Message        string
+	Metadata       map[string]string
+	Fingerprint    string
+	ContentHash    string
+	IdempotencyKey string
+	SentAt         *time.Time
+	ErrorCount     int
+	DeletedAt      *time.Time
+
+	// BodyOmitted is set when Message is empty because the body is stored separately and
+	// the query did not load it. GetNotification always loads it.
+	BodyOmitted bool
+}
+
+// selectStoredNotifications leaves overflow bodies in notification_bodies, so scans stay as
+// small as the notifications table. selectStoredNotificationsWithBodies joins them in for
+// the queries whose results are shown in full or delivered.
+const (
+	selectStoredNotifications = `
+SELECT n.notification_id, t.topic_name, n.timestamp, n.status, n.priority, n.send_at, n.message,
+	EXISTS(SELECT 1 FROM notification_bodies b WHERE b.notification_id = n.notification_id),
+	n.metadata, COALESCE(n.fingerprint, ''), COALESCE(n.content_hash, ''),
+	COALESCE(n.idempotency_key, ''), n.sent_at, n.error_count, n.deleted_at
+FROM notifications n
+JOIN topics t ON t.topic_id = n.topic_id`
+
+	selectStoredNotificationsWithBodies = `
+SELECT n.notification_id, t.topic_name, n.timestamp, n.status, n.priority, n.send_at, COALESCE(b.body, n.message),
+	FALSE,
+	n.metadata, COALESCE(n.fingerprint, ''), COALESCE(n.content_hash, ''),
+	COALESCE(n.idempotency_key, ''), n.sent_at, n.error_count, n.deleted_at
+FROM notifications n
+JOIN topics t ON t.topic_id = n.topic_id
+LEFT JOIN notification_bodies b ON b.notification_id = n.notification_id`
+)
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (f NotificationFilter) where(conditions []string, args []any) (string, []any) {
+	if f.Topic != "" {
+		conditions = append(conditions, "t.topic_name = ?")
+		args = append(args, f.Topic)
+	}
+	if len(f.Statuses) > 0 {
+		placeholders := make([]string, 0, len(f.Statuses))
+		for _, status := range f.Statuses {
+			placeholders = append(placeholders, "?")
+			args = append(args, status)
+		}
+		conditions = append(conditions, "n.status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "n.timestamp >= ?")
+		args = append(args, f.Since.UTC().Format(sqliteTimeFormat))
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "n.timestamp <= ?")
+		args = append(args, f.Until.UTC().Format(sqliteTimeFormat))
+	}
+	if !f.IncludeDeleted {
+		conditions = append(conditions, "n.deleted_at IS NULL")
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// queryNotifications runs selectQuery for the notifications matching filter and the extra
+// conditions in primary key order. A limit of zero or less returns all of them.
+func queryNotifications(ctx context.Context, q queryer, selectQuery string, filter NotificationFilter, conditions []string, args []any, limit int) ([]StoredNotification, error) {
+	where, args := filter.where(conditions, args)
+	query := selectQuery + where + " ORDER BY n.notification_id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	return collectNotifications(rows)
+}
+
+// collectNotifications scans and closes rows.
+func collectNotifications(rows *sql.Rows) ([]StoredNotification, error) {
+	defer rows.Close()
+
+	notifs := make([]StoredNotification, 0)
+	for rows.Next() {
+		notif, err := scanStoredNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifs = append(notifs, notif)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate notifications: %w", err)
+	}
+	return notifs, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanStoredNotification(row scanner) (StoredNotification, error) {
+	var notif StoredNotification
+	var metadata sql.NullString
+	var sendAt, sentAt, deletedAt sql.NullTime
+	if err := row.Scan(&notif.ID, &notif.Topic, &notif.Timestamp, &notif.Status, &notif.Priority, &sendAt, &notif.Message,
+		&notif.BodyOmitted, &metadata, &notif.Fingerprint, &notif.ContentHash, &notif.IdempotencyKey, &sentAt, &notif.ErrorCount, &deletedAt); err != nil {
+		return StoredNotification{}, fmt.Errorf("failed to scan notification: %w", err)
+	}
+	if sendAt.Valid {
+		notif.SendAt = &sendAt.Time
+	}
+	if sentAt.Valid {
+		notif.SentAt = &sentAt.Time
+	}
+	if deletedAt.Valid {
+		notif.DeletedAt = &deletedAt.Time
+	}
+
+	notif.Metadata = make(map[string]string)
+	if metadata.Valid && metadata.String != "" && metadata.String != "null" {
+		if err := json.Unmarshal([]byte(metadata.String), &notif.Metadata); err != nil {
+			return StoredNotification{}, fmt.Errorf("failed to unmarshal metadata of notification %d: %w", notif.ID, err)
+		}
+	}
+	return notif, nil
+}
+
+// ListNotifications returns the notifications matching filter, newest first, paged by
+// filter.Limit and filter.Offset. Overflow bodies are not loaded, see BodyOmitted.
+func (s *LibSQL) ListNotifications(ctx context.Context, filter NotificationFilter) ([]StoredNotification, error) {
+	where, args := filter.where(nil, nil)
+	query := selectStoredNotifications + where + " ORDER BY n.timestamp DESC, n.notification_id DESC"
+	if filter.Limit > 0 || filter.Offset > 0 {
+		limit := filter.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, filter.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	return collectNotifications(rows)
+}
+
+// GetNotification returns a single notification including its full message. Like every
+// other query it does not find soft-deleted notifications, GetNotificationIncludingDeleted
+// does. The error wraps sql.ErrNoRows if there is no such notification.
+func (s *LibSQL) GetNotification(ctx context.Context, notificationID int) (StoredNotification, error) {
+	return s.getNotification(ctx, notificationID, false)
+}
+
+// GetNotificationIncludingDeleted is GetNotification for soft-deleted notifications too.
+func (s *LibSQL) GetNotificationIncludingDeleted(ctx context.Context, notificationID int) (StoredNotification, error) {
+	return s.getNotification(ctx, notificationID, true)
+}
+
+func (s *LibSQL) getNotification(ctx context.Context, notificationID int, includeDeleted bool) (StoredNotification, error) {
+	where, args := NotificationFilter{IncludeDeleted: includeDeleted}.where(
+		[]string{"n.notification_id = ?"}, []any{notificationID})
+	row := s.db.QueryRowContext(ctx, selectStoredNotificationsWithBodies+where, args...)
+	notif, err := scanStoredNotification(row)
+	if err != nil {
+		return StoredNotification{}, fmt.Errorf("failed to get notification %d: %w", notificationID, err)
+	}
+	return notif, nil
+}
+
+// GetPendingNotifications returns up to limit notifications still in INPUT status, highest
+// priority first and oldest first within a priority. A limit of zero or less returns all
+// of them. They are about to be delivered, so their full messages are loaded.
+func (s *LibSQL) GetPendingNotifications(ctx context.Context, limit int) ([]StoredNotification, error) {
+	where, args := NotificationFilter{Statuses: []NotificationStatus{NotificationStatusInput}}.where(nil, nil)
+	query := selectStoredNotificationsWithBodies + where + " ORDER BY n.priority DESC, n.timestamp, n.notification_id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending notifications: %w", err)
+	}
+	return collectNotifications(rows)
+}
+
+// GetDueNotifications returns up to limit INPUT notifications that are unscheduled or whose
+// send_at is not after now, oldest first, with their full messages. A limit of zero or less
+// returns all of them.
+func (s *LibSQL) GetDueNotifications(ctx context.Context, now time.Time, limit int) ([]StoredNotification, error) {
+	return queryNotifications(ctx, s.db, selectStoredNotificationsWithBodies, NotificationFilter{Statuses: []NotificationStatus{NotificationStatusInput}},
+		[]string{"(n.send_at IS NULL OR n.send_at <= ?)"}, []any{now.UTC().Format(sqliteTimeFormat)}, limit)
+}
+
+func (s *LibSQL) FindByFingerprint(ctx context.Context, fingerprint string) ([]StoredNotification, error) {
+	return queryNotifications(ctx, s.db, selectStoredNotifications, NotificationFilter{},
+		[]string{"n.fingerprint = ?"}, []any{fingerprint}, 0)
+}
+
+func (s *LibSQL) CountNotifications(ctx context.Context, filter NotificationFilter) (int, error) {
+	where, args := filter.where(nil, nil)
+	var count int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM notifications n JOIN topics t ON t.topic_id = n.topic_id"+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return count, nil
+}
+
+// CountByStatus counts the notifications in every status with a single query. The map
+// always holds all known statuses, soft-deleted notifications are not counted.
+func (s *LibSQL) CountByStatus(ctx context.Context) (map[NotificationStatus]int, error) {
+	return s.countByStatus(ctx, NotificationFilter{})
+}
+
+// CountByTopic is CountByStatus for the notifications of one topic.
+func (s *LibSQL) CountByTopic(ctx context.Context, topicName string) (map[NotificationStatus]int, error) {
+	return s.countByStatus(ctx, NotificationFilter{Topic: topicName})
+}
+
+func (s *LibSQL) countByStatus(ctx context.Context, filter NotificationFilter) (map[NotificationStatus]int, error) {
+	where, args := filter.where(nil, nil)
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT n.status, COUNT(*) FROM notifications n JOIN topics t ON t.topic_id = n.topic_id"+where+" GROUP BY n.status",
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count notifications by status: %w", err)
+	}
+	defer rows.Close()
+
+	counts := newStatusCounts()
+	for rows.Next() {
+		var status NotificationStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan status count: %w", err)
+		}
+		counts[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate status counts: %w", err)
+	}
+	return counts, nil
+}
+
+func newStatusCounts() map[NotificationStatus]int {
+	return map[NotificationStatus]int{
+		NotificationStatusInput: 0,
+		NotificationStatusSent:  0,
+		NotificationStatusError: 0,
+	}
+}
@@ -0,0 +1,208 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("identical content shares a fingerprint", func(t *testing.T) {
+		database := setupTestDB(t)
+		defer database.Close()
+
+		notif := exchange.Notification{
+			Topic:    "fingerprint",
+			Message:  "disk full",
+			Metadata: map[string]string{"host": "a"},
+		}
+		id1, err := database.InsertNotification(ctx, notif)
+		require.NoError(t, err)
+
+		notif.Metadata = map[string]string{"host": "b"}
+		id2, err := database.InsertNotification(ctx, notif)
+		require.NoError(t, err)
+
+		_, err = database.InsertNotification(ctx, exchange.Notification{Topic: "fingerprint", Message: "disk ok"})
+		require.NoError(t, err)
+
+		found, err := database.FindByFingerprint(ctx, notif.Fingerprint())
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		assert.Equal(t, id1, found[0].ID)
+		assert.Equal(t, id2, found[1].ID)
+		assert.Equal(t, found[0].Fingerprint, found[1].Fingerprint)
+	})
+
+	t.Run("configured metadata keys are included", func(t *testing.T) {
+		database := setupTestDB(t, db.WithFingerprintMetadata("host"))
+		defer database.Close()
+
+		notif := exchange.Notification{
+			Topic:    "fingerprint",
+			Message:  "disk full",
+			Metadata: map[string]string{"host": "a", "date": "today"},
+		}
+		_, err := database.InsertNotification(ctx, notif)
+		require.NoError(t, err)
+
+		notif.Metadata = map[string]string{"host": "b", "date": "today"}
+		_, err = database.InsertNotification(ctx, notif)
+		require.NoError(t, err)
+
+		found, err := database.FindByFingerprint(ctx, notif.Fingerprint("host"))
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, "b", found[0].Metadata["host"])
+	})
+
+	t.Run("unknown fingerprint", func(t *testing.T) {
+		database := setupTestDB(t)
+		defer database.Close()
+
+		found, err := database.FindByFingerprint(ctx, "does-not-exist")
+		require.NoError(t, err)
+		assert.Empty(t, found)
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	notif := exchange.Notification{Topic: "soft_delete", Message: "same message"}
+	keptID, err := database.InsertNotification(ctx, notif)
+	require.NoError(t, err)
+	deletedID, err := database.InsertNotification(ctx, notif)
+	require.NoError(t, err)
+	require.NoError(t, database.MarkNotificationSent(ctx, keptID))
+	require.NoError(t, database.MarkNotificationSent(ctx, deletedID))
+
+	require.NoError(t, database.SoftDeleteNotification(ctx, deletedID))
+
+	t.Run("hidden by default", func(t *testing.T) {
+		found, err := database.FindByFingerprint(ctx, notif.Fingerprint())
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, keptID, found[0].ID)
+		assert.Nil(t, found[0].DeletedAt)
+
+		count, err := database.CountNotifications(ctx, db.NotificationFilter{Topic: "soft_delete"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		info, err := database.Info(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, info.Notifications)
+
+		sender := &recordingSender{}
+		_, err = database.RedeliverNotifications(ctx, db.NotificationFilter{Topic: "soft_delete"}, sender)
+		require.NoError(t, err)
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, keptID, sender.sent[0].ID)
+	})
+
+	t.Run("visible with include flag", func(t *testing.T) {
+		filter := db.NotificationFilter{Topic: "soft_delete", IncludeDeleted: true}
+
+		count, err := database.CountNotifications(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		sender := &recordingSender{}
+		_, err = database.RedeliverNotifications(ctx, filter, sender)
+		require.NoError(t, err)
+		require.Len(t, sender.sent, 2)
+		assert.Nil(t, sender.sent[0].DeletedAt)
+		require.NotNil(t, sender.sent[1].DeletedAt)
+		assert.Equal(t, deletedID, sender.sent[1].ID)
+	})
+
+	t.Run("deleting twice or unknown ids is a no-op", func(t *testing.T) {
+		assert.NoError(t, database.SoftDeleteNotification(ctx, deletedID))
+		assert.NoError(t, database.SoftDeleteNotification(ctx, 99999))
+	})
+}
+
+func TestListNotifications(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	var ids []int
+	for i, topic := range []string{"alpha", "beta", "alpha", "beta", "alpha"} {
+		id, err := database.InsertNotification(ctx, exchange.Notification{Topic: topic, Message: fmt.Sprintf("message %d", i)})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	require.NoError(t, database.MarkNotificationSent(ctx, ids[0]))
+	require.NoError(t, database.MarkNotificationError(ctx, ids[1]))
+	require.NoError(t, database.MarkNotificationSent(ctx, ids[2]))
+
+	t.Run("empty filter", func(t *testing.T) {
+		all, err := database.ListNotifications(ctx, db.NotificationFilter{})
+		require.NoError(t, err)
+		require.Len(t, all, 5)
+		assert.Equal(t, ids[4], all[0].ID, "newest first")
+		assert.Equal(t, ids[0], all[4].ID)
+
+		limited, err := database.ListNotifications(ctx, db.NotificationFilter{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, limited, 2)
+		assert.Equal(t, []int{ids[4], ids[3]}, []int{limited[0].ID, limited[1].ID})
+
+		page, err := database.ListNotifications(ctx, db.NotificationFilter{Limit: 2, Offset: 2})
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, []int{ids[2], ids[1]}, []int{page[0].ID, page[1].ID})
+
+		rest, err := database.ListNotifications(ctx, db.NotificationFilter{Offset: 3})
+		require.NoError(t, err)
+		assert.Len(t, rest, 2)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		sent, err := database.ListNotifications(ctx, db.NotificationFilter{Statuses: []db.NotificationStatus{db.NotificationStatusSent}})
+		require.NoError(t, err)
+		require.Len(t, sent, 2)
+		assert.Equal(t, []int{ids[2], ids[0]}, []int{sent[0].ID, sent[1].ID})
+
+		failed, err := database.ListNotifications(ctx, db.NotificationFilter{
+			Statuses: []db.NotificationStatus{db.NotificationStatusError, db.NotificationStatusInput},
+		})
+		require.NoError(t, err)
+		assert.Len(t, failed, 3)
+
+		alpha, err := database.ListNotifications(ctx, db.NotificationFilter{
+			Topic:    "alpha",
+			Statuses: []db.NotificationStatus{db.NotificationStatusInput},
+		})
+		require.NoError(t, err)
+		require.Len(t, alpha, 1)
+		assert.Equal(t, ids[4], alpha[0].ID)
+	})
+
+	t.Run("time window", func(t *testing.T) {
+		now := time.Now()
+		inside, err := database.ListNotifications(ctx, db.NotificationFilter{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)})
+		require.NoError(t, err)
+		assert.Len(t, inside, 5)
+
+		future, err := database.ListNotifications(ctx, db.NotificationFilter{Since: now.Add(time.Hour)})
+		require.NoError(t, err)
+		assert.Empty(t, future)
+
+		past, err := database.ListNotifications(ctx, db.NotificationFilter{Until: now.Add(-time.Hour)})
+		require.NoError(t, err)
+		assert.Empty(t, past)
+	})
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Sender interface {
+	Send(ctx context.Context, notif StoredNotification) error
+}
+
+type DeliveryAttempt struct {
+	ID             int
+	NotificationID int
+	AttemptedAt    time.Time
+	Redelivery     bool
+	Error          string
+}
+
+// RedeliverNotifications hands every SENT notification matching filter to sender again
+// and records a delivery attempt for each. The stored status is left untouched, failed
+// sends are recorded and returned joined after all notifications were tried.
+func (s *LibSQL) RedeliverNotifications(ctx context.Context, filter NotificationFilter, sender Sender) (int, error) {
+	notifs, err := queryNotifications(ctx, s.db, selectStoredNotificationsWithBodies, filter,
+		[]string{"n.status = ?"}, []any{NotificationStatusSent}, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	var sendErrs []error
+	for _, notif := range notifs {
+		sendErr := sender.Send(ctx, notif)
+		if err := s.recordDeliveryAttempt(ctx, notif.ID, true, sendErr); err != nil {
+			return delivered, err
+		}
+		if sendErr != nil {
+			sendErrs = append(sendErrs, fmt.Errorf("failed to redeliver notification %d: %w", notif.ID, sendErr))
+			continue
+		}
+		delivered++
+	}
+
+	return delivered, errors.Join(sendErrs...)
+}
+
+func (s *LibSQL) recordDeliveryAttempt(ctx context.Context, notificationID int, redelivery bool, sendErr error) error {
+	var errText any
+	if sendErr != nil {
+		errText = sendErr.Error()
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		"INSERT INTO delivery_attempts (notification_id, redelivery, error) VALUES (?, ?, ?)",
+		notificationID, redelivery, errText); err != nil {
+		return fmt.Errorf("failed to record delivery attempt: %w", err)
+	}
+	return nil
+}
+
+func (s *LibSQL) ListDeliveryAttempts(ctx context.Context, notificationID int) ([]DeliveryAttempt, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT attempt_id, notification_id, attempted_at, redelivery, COALESCE(error, '')
+		FROM delivery_attempts WHERE notification_id = ? ORDER BY attempt_id`, notificationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := make([]DeliveryAttempt, 0)
+	for rows.Next() {
+		var a DeliveryAttempt
+		if err := rows.Scan(&a.ID, &a.NotificationID, &a.AttemptedAt, &a.Redelivery, &a.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery attempt: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate delivery attempts: %w", err)
+	}
+	return attempts, nil
+}
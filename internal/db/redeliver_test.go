@@ -0,0 +1,105 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSender struct {
+	sent []db.StoredNotification
+	err  error
+}
+
+func (s *recordingSender) Send(ctx context.Context, notif db.StoredNotification) error {
+	s.sent = append(s.sent, notif)
+	return s.err
+}
+
+func TestRedeliverNotifications(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	insert := func(topic, message string) int {
+		id, err := database.InsertNotification(ctx, exchange.Notification{
+			Topic:    topic,
+			Message:  message,
+			Metadata: map[string]string{"key": "value"},
+		})
+		require.NoError(t, err)
+		return id
+	}
+
+	sentA := insert("redeliver_a", "sent a")
+	pendingA := insert("redeliver_a", "pending a")
+	sentB := insert("redeliver_b", "sent b")
+	require.NoError(t, database.MarkNotificationSent(ctx, sentA))
+	require.NoError(t, database.MarkNotificationSent(ctx, sentB))
+
+	t.Run("only sent notifications of the topic", func(t *testing.T) {
+		sender := &recordingSender{}
+		n, err := database.RedeliverNotifications(ctx, db.NotificationFilter{Topic: "redeliver_a"}, sender)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, sentA, sender.sent[0].ID)
+		assert.Equal(t, "redeliver_a", sender.sent[0].Topic)
+		assert.Equal(t, "sent a", sender.sent[0].Message)
+		assert.Equal(t, map[string]string{"key": "value"}, sender.sent[0].Metadata)
+		assert.Equal(t, db.NotificationStatusSent, sender.sent[0].Status)
+
+		attempts, err := database.ListDeliveryAttempts(ctx, sentA)
+		require.NoError(t, err)
+		require.Len(t, attempts, 1)
+		assert.True(t, attempts[0].Redelivery)
+		assert.Empty(t, attempts[0].Error)
+
+		attempts, err = database.ListDeliveryAttempts(ctx, pendingA)
+		require.NoError(t, err)
+		assert.Empty(t, attempts)
+	})
+
+	t.Run("time range", func(t *testing.T) {
+		sender := &recordingSender{}
+		n, err := database.RedeliverNotifications(ctx, db.NotificationFilter{
+			Until: time.Now().Add(-time.Hour),
+		}, sender)
+		require.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.Empty(t, sender.sent)
+
+		n, err = database.RedeliverNotifications(ctx, db.NotificationFilter{
+			Since: time.Now().Add(-time.Hour),
+			Until: time.Now().Add(time.Hour),
+		}, sender)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("failed sends keep status and are recorded", func(t *testing.T) {
+		sender := &recordingSender{err: errors.New("endpoint down")}
+		n, err := database.RedeliverNotifications(ctx, db.NotificationFilter{Topic: "redeliver_b"}, sender)
+		assert.Error(t, err)
+		assert.Equal(t, 0, n)
+
+		attempts, err := database.ListDeliveryAttempts(ctx, sentB)
+		require.NoError(t, err)
+		require.NotEmpty(t, attempts)
+		assert.Equal(t, "endpoint down", attempts[len(attempts)-1].Error)
+
+		// Already SENT, so this must still be a no-op.
+		require.NoError(t, database.MarkNotificationError(ctx, sentB))
+		sender.err = nil
+		n, err = database.RedeliverNotifications(ctx, db.NotificationFilter{Topic: "redeliver_b"}, sender)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+}
@@ -1,5 +1,8 @@
 package db
 
+// SchemaVersion is the version of the last entry in migrations.
+const SchemaVersion = 16
+
 type NotificationStatus string
 
 const (
@@ -8,24 +11,34 @@ const (
 	NotificationStatusError NotificationStatus = "ERROR"
 )
 
-const CREATE_DEVICES_TABLE = `
+const CREATE_SCHEMA_MIGRATIONS_TABLE = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+type migration struct {
+	version int
+	name    string
+	up      string
+}
+
+// migrations are applied in order by Initialize, each exactly once. Append new steps with
+// the next version and bump SchemaVersion, never edit a step that has been released.
+var migrations = []migration{
+	{version: 1, name: "base tables", up: `
 CREATE TABLE IF NOT EXISTS devices (
 	device_id TEXT PRIMARY KEY,
 	public_key TEXT NOT NULL,
 	registration_date DATETIME DEFAULT CURRENT_TIMESTAMP
 );
-`
-
-const CREATE_TOPICS_TABLE = `
 CREATE TABLE IF NOT EXISTS topics (
 	topic_id INTEGER PRIMARY KEY AUTOINCREMENT,
 	topic_name TEXT NOT NULL UNIQUE,
 	description TEXT,
 	creation_date DATETIME DEFAULT CURRENT_TIMESTAMP
 );
-`
-
-const CREATE_NOTIFICATIONS_TABLE = `
 CREATE TABLE IF NOT EXISTS notifications (
 	notification_id INTEGER PRIMARY KEY AUTOINCREMENT,
 	topic_id INTEGER NOT NULL,
@@ -35,6 +48,90 @@ CREATE TABLE IF NOT EXISTS notifications (
 	status TEXT CHECK(status IN ('INPUT', 'SENT', 'ERROR')) DEFAULT 'INPUT',
 	FOREIGN KEY(topic_id) REFERENCES topics(topic_id)
 );
-`
-
-const CREATE_ALL_TABLES = CREATE_DEVICES_TABLE + CREATE_TOPICS_TABLE + CREATE_NOTIFICATIONS_TABLE
+`},
+	{version: 2, name: "delivery attempts", up: `
+CREATE TABLE IF NOT EXISTS delivery_attempts (
+	attempt_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	notification_id INTEGER NOT NULL,
+	attempted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	redelivery INTEGER NOT NULL DEFAULT 0,
+	error TEXT,
+	FOREIGN KEY(notification_id) REFERENCES notifications(notification_id)
+);
+`},
+	{version: 3, name: "fingerprint", up: `
+ALTER TABLE notifications ADD COLUMN fingerprint TEXT;
+CREATE INDEX IF NOT EXISTS idx_notifications_fingerprint ON notifications(fingerprint);
+`},
+	{version: 4, name: "device endpoint", up: `
+ALTER TABLE devices ADD COLUMN endpoint TEXT;
+`},
+	{version: 5, name: "soft delete", up: `
+ALTER TABLE notifications ADD COLUMN deleted_at DATETIME;
+`},
+	{version: 6, name: "device locale", up: `
+ALTER TABLE devices ADD COLUMN locale TEXT;
+`},
+	// Silence rules reference topics by name, a rule may be set up before its producer ever
+	// sent anything.
+	{version: 7, name: "silence rules", up: `
+CREATE TABLE IF NOT EXISTS silence_rules (
+	topic_name TEXT PRIMARY KEY,
+	interval_seconds INTEGER NOT NULL CHECK(interval_seconds > 0),
+	creation_date DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`},
+	// Messages over the overflow threshold live here, their notifications row keeps an empty
+	// message so scans over notifications stay small.
+	{version: 8, name: "notification bodies", up: `
+CREATE TABLE IF NOT EXISTS notification_bodies (
+	notification_id INTEGER PRIMARY KEY,
+	body TEXT NOT NULL,
+	FOREIGN KEY(notification_id) REFERENCES notifications(notification_id)
+);
+`},
+	{version: 9, name: "audit log", up: `
+CREATE TABLE IF NOT EXISTS audit_log (
+	audit_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	actor TEXT NOT NULL,
+	action TEXT NOT NULL,
+	target TEXT,
+	status INTEGER NOT NULL,
+	outcome TEXT NOT NULL
+);
+`},
+	{version: 10, name: "notification priority", up: `
+ALTER TABLE notifications ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_notifications_pending ON notifications(status, priority DESC, timestamp);
+`},
+	{version: 11, name: "scheduled delivery", up: `
+ALTER TABLE notifications ADD COLUMN send_at DATETIME;
+`},
+	{version: 12, name: "subscriptions", up: `
+CREATE TABLE IF NOT EXISTS subscriptions (
+	device_id TEXT NOT NULL,
+	topic_id INTEGER NOT NULL,
+	creation_date DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(device_id, topic_id),
+	FOREIGN KEY(device_id) REFERENCES devices(device_id),
+	FOREIGN KEY(topic_id) REFERENCES topics(topic_id)
+);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_topic ON subscriptions(topic_id);
+`},
+	{version: 13, name: "content hash", up: `
+ALTER TABLE notifications ADD COLUMN content_hash TEXT;
+CREATE INDEX IF NOT EXISTS idx_notifications_content_hash ON notifications(content_hash, timestamp);
+`},
+	{version: 14, name: "idempotency key", up: `
+ALTER TABLE notifications ADD COLUMN idempotency_key TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_idempotency_key ON notifications(idempotency_key);
+`},
+	{version: 15, name: "delivery history", up: `
+ALTER TABLE notifications ADD COLUMN sent_at DATETIME;
+ALTER TABLE notifications ADD COLUMN error_count INTEGER NOT NULL DEFAULT 0;
+`},
+	{version: 16, name: "device signing key", up: `
+ALTER TABLE devices ADD COLUMN signing_key TEXT;
+`},
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidSilenceInterval = errors.New("silence interval must be at least one second")
+
+// SilenceRule expects at least one notification on Topic every Interval.
+type SilenceRule struct {
+	Topic     string
+	Interval  time.Duration
+	CreatedAt time.Time
+}
+
+// SetSilenceRule creates the rule for rule.Topic or replaces its interval.
+func (s *LibSQL) SetSilenceRule(ctx context.Context, rule SilenceRule) error {
+	if err := validateTopic(rule.Topic, s.topicPattern); err != nil {
+		return err
+	}
+	seconds := int64(rule.Interval / time.Second)
+	if seconds < 1 {
+		return ErrInvalidSilenceInterval
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO silence_rules (topic_name, interval_seconds) VALUES (?, ?)
+		ON CONFLICT(topic_name) DO UPDATE SET interval_seconds = excluded.interval_seconds`,
+		rule.Topic, seconds); err != nil {
+		return fmt.Errorf("failed to set silence rule: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// DeleteSilenceRule removes the rule for topic. Deleting a missing rule is a no-op.
+func (s *LibSQL) DeleteSilenceRule(ctx context.Context, topic string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM silence_rules WHERE topic_name = ?", topic); err != nil {
+		return fmt.Errorf("failed to delete silence rule: %w", err)
+	}
+	return nil
+}
+
+func (s *LibSQL) ListSilenceRules(ctx context.Context) ([]SilenceRule, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT topic_name, interval_seconds, creation_date FROM silence_rules ORDER BY topic_name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query silence rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]SilenceRule, 0)
+	for rows.Next() {
+		var r SilenceRule
+		var seconds int64
+		if err := rows.Scan(&r.Topic, &seconds, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan silence rule: %w", err)
+		}
+		r.Interval = time.Duration(seconds) * time.Second
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate silence rules: %w", err)
+	}
+	return rules, nil
+}
+
+// LastNotificationTimes returns the timestamp of the newest notification per topic. Topics
+// without notifications are missing from the map.
+func (s *LibSQL) LastNotificationTimes(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT t.topic_name, MAX(n.timestamp) FROM notifications n
+		JOIN topics t ON t.topic_id = n.topic_id
+		GROUP BY t.topic_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last notification times: %w", err)
+	}
+	defer rows.Close()
+
+	last := make(map[string]time.Time)
+	for rows.Next() {
+		var topic, timestamp string
+		if err := rows.Scan(&topic, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan last notification time: %w", err)
+		}
+		t, err := parseSQLiteTime(timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse last notification time of %s: %w", topic, err)
+		}
+		last[topic] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate last notification times: %w", err)
+	}
+	return last, nil
+}
+
+// parseSQLiteTime parses timestamps that come back from aggregates as plain text, either in
+// the CURRENT_TIMESTAMP layout or as RFC 3339.
+func parseSQLiteTime(value string) (time.Time, error) {
+	if t, err := time.Parse(sqliteTimeFormat, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
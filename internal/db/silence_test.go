@@ -0,0 +1,54 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilenceRules(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	assert.ErrorIs(t, database.SetSilenceRule(ctx, db.SilenceRule{Topic: "t", Interval: time.Millisecond}), db.ErrInvalidSilenceInterval)
+	assert.ErrorIs(t, database.SetSilenceRule(ctx, db.SilenceRule{Interval: time.Hour}), db.ErrEmptyTopic)
+
+	require.NoError(t, database.SetSilenceRule(ctx, db.SilenceRule{Topic: "b", Interval: time.Hour}))
+	require.NoError(t, database.SetSilenceRule(ctx, db.SilenceRule{Topic: "a", Interval: time.Minute}))
+	require.NoError(t, database.SetSilenceRule(ctx, db.SilenceRule{Topic: "b", Interval: 2 * time.Hour}))
+
+	rules, err := database.ListSilenceRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "a", rules[0].Topic)
+	assert.Equal(t, time.Minute, rules[0].Interval)
+	assert.Equal(t, "b", rules[1].Topic)
+	assert.Equal(t, 2*time.Hour, rules[1].Interval)
+
+	require.NoError(t, database.DeleteSilenceRule(ctx, "a"))
+	rules, err = database.ListSilenceRules(ctx)
+	require.NoError(t, err)
+	assert.Len(t, rules, 1)
+}
+
+func TestLastNotificationTimes(t *testing.T) {
+	ctx := context.Background()
+	database := setupTestDB(t)
+	defer database.Close()
+
+	before := time.Now().UTC().Add(-time.Second)
+	_, err := database.InsertNotification(ctx, exchange.Notification{Topic: "a", Message: "m"})
+	require.NoError(t, err)
+
+	last, err := database.LastNotificationTimes(ctx)
+	require.NoError(t, err)
+	require.Contains(t, last, "a")
+	assert.False(t, last["a"].Before(before.Truncate(time.Second)))
+	assert.NotContains(t, last, "b")
+}
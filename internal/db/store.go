@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/dikkadev/cland/pkg/exchange"
+)
+
+// Store is everything cland needs from its persistence layer. LibSQL implements it on top
+// of SQLite/libSQL, Memory keeps all data in process. Other backends only have to satisfy
+// this interface, the exchange package itself depends on nothing but exchange.Store.
+type Store interface {
+	exchange.Store
+	exchange.Validator
+
+	InsertDevice(ctx context.Context, deviceID, publicKey string) error
+	RegisterDevice(ctx context.Context, device Device) error
+	ListDevices(ctx context.Context) ([]Device, error)
+	GetDevicePublicKey(ctx context.Context, deviceID string) (string, error)
+	GetDeviceSigningKey(ctx context.Context, deviceID string) (string, error)
+
+	GetOrCreateTopic(ctx context.Context, topicName string, description string) (int, error)
+
+	Subscribe(ctx context.Context, deviceID, topicName string) error
+	Unsubscribe(ctx context.Context, deviceID, topicName string) error
+	DevicesForTopic(ctx context.Context, topicName string) ([]Device, error)
+
+	GetNotification(ctx context.Context, notificationID int) (StoredNotification, error)
+	GetNotificationIncludingDeleted(ctx context.Context, notificationID int) (StoredNotification, error)
+	GetPendingNotifications(ctx context.Context, limit int) ([]StoredNotification, error)
+	GetDueNotifications(ctx context.Context, now time.Time, limit int) ([]StoredNotification, error)
+	ListNotifications(ctx context.Context, filter NotificationFilter) ([]StoredNotification, error)
+	CountNotifications(ctx context.Context, filter NotificationFilter) (int, error)
+	CountByStatus(ctx context.Context) (map[NotificationStatus]int, error)
+	CountByTopic(ctx context.Context, topicName string) (map[NotificationStatus]int, error)
+	FindByFingerprint(ctx context.Context, fingerprint string) ([]StoredNotification, error)
+	IterateNotifications(ctx context.Context, fn func(StoredNotification) error) error
+	SoftDeleteNotification(ctx context.Context, notificationID int) error
+	DeleteNotificationsBefore(ctx context.Context, cutoff time.Time, statuses ...NotificationStatus) (int, error)
+
+	MarkNotificationSent(ctx context.Context, notificationID int) error
+	MarkNotificationError(ctx context.Context, notificationID int) error
+	TryMarkNotificationSent(ctx context.Context, notificationID int) (bool, error)
+	TryMarkNotificationError(ctx context.Context, notificationID int) (bool, error)
+	RequeueNotification(ctx context.Context, notificationID int) error
+	RequeueAllErrors(ctx context.Context) (int, error)
+
+	Close() error
+}
+
+var (
+	_ Store = (*LibSQL)(nil)
+	_ Store = (*Memory)(nil)
+
+	_ exchange.SigningKeyStore = (*LibSQL)(nil)
+)
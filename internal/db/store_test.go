@@ -0,0 +1,534 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStoreConformance runs the same behavioural checks against every Store implementation.
+func TestStoreConformance(t *testing.T) {
+	stores := map[string]func(t *testing.T) db.Store{
+		"libsql": func(t *testing.T) db.Store { return setupTestDB(t, db.WithDedupWindow(time.Hour)) },
+		"memory": func(t *testing.T) db.Store { return db.NewMemory(db.WithMemoryDedupWindow(time.Hour)) },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, newStore)
+		})
+	}
+}
+
+func testStore(t *testing.T, newStore func(t *testing.T) db.Store) {
+	ctx := context.Background()
+
+	t.Run("devices", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		require.NoError(t, store.InsertDevice(ctx, "b", "key-b"))
+		require.NoError(t, store.RegisterDevice(ctx, db.Device{ID: "a", PublicKey: "key-a", SigningKey: "signing-a", Endpoint: "https://push.example.com/a"}))
+		assert.Error(t, store.InsertDevice(ctx, "a", "other"))
+		assert.ErrorIs(t, store.InsertDevice(ctx, "", "key"), db.ErrEmptyDeviceID)
+		assert.ErrorIs(t, store.InsertDevice(ctx, "c", ""), db.ErrEmptyPublicKey)
+		assert.ErrorIs(t, store.RegisterDevice(ctx, db.Device{ID: "c", PublicKey: "key", Endpoint: "ftp://x"}), db.ErrInvalidEndpoint)
+
+		devices, err := store.ListDevices(ctx)
+		require.NoError(t, err)
+		require.Len(t, devices, 2)
+		assert.Equal(t, "a", devices[0].ID)
+		assert.Equal(t, "https://push.example.com/a", devices[0].Endpoint)
+		assert.Equal(t, "b", devices[1].ID)
+		assert.Empty(t, devices[1].Endpoint)
+
+		key, err := store.GetDevicePublicKey(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "key-a", key)
+		_, err = store.GetDevicePublicKey(ctx, "unknown")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		assert.Equal(t, "signing-a", devices[0].SigningKey)
+		key, err = store.GetDeviceSigningKey(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "signing-a", key)
+		_, err = store.GetDeviceSigningKey(ctx, "b")
+		assert.ErrorIs(t, err, sql.ErrNoRows, "no signing key")
+		_, err = store.GetDeviceSigningKey(ctx, "unknown")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("subscriptions", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		require.NoError(t, store.InsertDevice(ctx, "b", "key-b"))
+		require.NoError(t, store.RegisterDevice(ctx, db.Device{ID: "a", PublicKey: "key-a", Endpoint: "https://push.example/a", Locale: "de"}))
+		require.NoError(t, store.Subscribe(ctx, "b", "alerts"))
+		require.NoError(t, store.Subscribe(ctx, "a", "alerts"))
+		require.NoError(t, store.Subscribe(ctx, "a", "alerts"), "subscribing twice")
+		require.NoError(t, store.Subscribe(ctx, "a", "builds"))
+		assert.ErrorIs(t, store.Subscribe(ctx, "unknown", "alerts"), db.ErrUnknownDevice)
+		assert.ErrorIs(t, store.Subscribe(ctx, "a", ""), db.ErrEmptyTopic)
+
+		ids := func(devices []db.Device) []string {
+			ids := make([]string, 0, len(devices))
+			for _, d := range devices {
+				ids = append(ids, d.ID)
+			}
+			return ids
+		}
+
+		devices, err := store.DevicesForTopic(ctx, "alerts")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, ids(devices))
+		assert.Equal(t, "key-a", devices[0].PublicKey)
+		assert.Equal(t, "https://push.example/a", devices[0].Endpoint)
+		assert.Equal(t, "de", devices[0].Locale)
+		assert.Equal(t, "key-b", devices[1].PublicKey)
+
+		require.NoError(t, store.Unsubscribe(ctx, "a", "alerts"))
+		require.NoError(t, store.Unsubscribe(ctx, "a", "alerts"), "unsubscribing twice")
+		require.NoError(t, store.Unsubscribe(ctx, "a", "missing"))
+		devices, err = store.DevicesForTopic(ctx, "alerts")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b"}, ids(devices))
+
+		devices, err = store.DevicesForTopic(ctx, "builds")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, ids(devices))
+		devices, err = store.DevicesForTopic(ctx, "missing")
+		require.NoError(t, err)
+		assert.Empty(t, devices)
+	})
+
+	t.Run("topics", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		id, err := store.GetOrCreateTopic(ctx, "alerts", "")
+		require.NoError(t, err)
+		again, err := store.GetOrCreateTopic(ctx, "alerts", "")
+		require.NoError(t, err)
+		assert.Equal(t, id, again)
+
+		other, err := store.GetOrCreateTopic(ctx, "builds", "")
+		require.NoError(t, err)
+		assert.NotEqual(t, id, other)
+
+		_, err = store.GetOrCreateTopic(ctx, "", "")
+		assert.ErrorIs(t, err, db.ErrEmptyTopic)
+	})
+
+	t.Run("notifications", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		_, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts"})
+		assert.ErrorIs(t, err, db.ErrEmptyMessage)
+		err = store.ValidateNotification(exchange.Notification{Topic: "alerts"})
+		assert.ErrorIs(t, err, db.ErrEmptyMessage)
+		assert.ErrorIs(t, err, db.ErrInvalidNotification)
+		assert.NoError(t, store.ValidateNotification(exchange.Notification{Topic: "alerts", Message: "disk full"}))
+
+		notif := exchange.Notification{Topic: "alerts", Message: "disk full", Metadata: map[string]string{"host": "a"}}
+		first, err := store.InsertNotification(ctx, notif)
+		require.NoError(t, err)
+		second, err := store.InsertNotification(ctx, notif)
+		require.NoError(t, err)
+		_, err = store.InsertNotification(ctx, exchange.Notification{Topic: "builds", Message: "green"})
+		require.NoError(t, err)
+
+		count, err := store.CountNotifications(ctx, db.NotificationFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+		count, err = store.CountNotifications(ctx, db.NotificationFilter{Topic: "alerts"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		matches, err := store.FindByFingerprint(ctx, notif.Fingerprint())
+		require.NoError(t, err)
+		require.Len(t, matches, 2)
+		assert.Equal(t, first, matches[0].ID)
+		assert.Equal(t, db.NotificationStatusInput, matches[0].Status)
+		assert.Equal(t, map[string]string{"host": "a"}, matches[0].Metadata)
+
+		require.NoError(t, store.SoftDeleteNotification(ctx, second))
+		count, err = store.CountNotifications(ctx, db.NotificationFilter{Topic: "alerts"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		_, err = store.GetNotification(ctx, second)
+		assert.ErrorIs(t, err, sql.ErrNoRows, "soft-deleted")
+		deleted, err := store.GetNotificationIncludingDeleted(ctx, second)
+		require.NoError(t, err)
+		assert.Equal(t, second, deleted.ID)
+		assert.NotNil(t, deleted.DeletedAt)
+		_, err = store.GetNotificationIncludingDeleted(ctx, 9999)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		count, err = store.CountNotifications(ctx, db.NotificationFilter{Topic: "alerts", IncludeDeleted: true})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		var iterated []int
+		require.NoError(t, store.IterateNotifications(ctx, func(n db.StoredNotification) error {
+			iterated = append(iterated, n.ID)
+			return nil
+		}))
+		assert.Len(t, iterated, 3)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		first, err := store.InsertNotification(ctx, exchange.Notification{Topic: "dedup", Message: "one", ContentHash: "hash-one"})
+		require.NoError(t, err)
+		_, err = store.InsertNotification(ctx, exchange.Notification{Topic: "dedup", Message: "one", ContentHash: "hash-one"})
+		assert.ErrorIs(t, err, db.ErrDuplicateNotification)
+		_, err = store.InsertNotification(ctx, exchange.Notification{Topic: "dedup", Message: "two", ContentHash: "hash-two"})
+		assert.NoError(t, err)
+
+		// Without a hash nothing is deduplicated.
+		for range 2 {
+			_, err = store.InsertNotification(ctx, exchange.Notification{Topic: "dedup", Message: "no hash"})
+			assert.NoError(t, err)
+		}
+
+		stored, err := store.GetNotification(ctx, first)
+		require.NoError(t, err)
+		assert.Equal(t, "hash-one", stored.ContentHash)
+
+		require.NoError(t, store.SoftDeleteNotification(ctx, first))
+		_, err = store.InsertNotification(ctx, exchange.Notification{Topic: "dedup", Message: "one", ContentHash: "hash-one"})
+		assert.NoError(t, err, "deleted notifications are not duplicates")
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		notif := exchange.Notification{Topic: "jobs", Message: "first", IdempotencyKey: "job-42"}
+		first, err := store.InsertNotification(ctx, notif)
+		require.NoError(t, err)
+
+		notif.Message = "retried"
+		again, err := store.InsertNotification(ctx, notif)
+		assert.ErrorIs(t, err, db.ErrDuplicateNotification)
+		assert.Equal(t, first, again)
+
+		other, err := store.InsertNotification(ctx, exchange.Notification{Topic: "jobs", Message: "first", IdempotencyKey: "job-43"})
+		require.NoError(t, err)
+		assert.NotEqual(t, first, other)
+
+		stored, err := store.GetNotification(ctx, first)
+		require.NoError(t, err)
+		assert.Equal(t, "job-42", stored.IdempotencyKey)
+		assert.Equal(t, "first", stored.Message)
+
+		count, err := store.CountNotifications(ctx, db.NotificationFilter{Topic: "jobs"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("status transitions", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		sent, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "sent"})
+		require.NoError(t, err)
+		failed, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "failed"})
+		require.NoError(t, err)
+
+		require.NoError(t, store.MarkNotificationSent(ctx, sent))
+		require.NoError(t, store.MarkNotificationError(ctx, failed))
+		// Only INPUT notifications transition, so these are no-ops.
+		require.NoError(t, store.MarkNotificationError(ctx, sent))
+		require.NoError(t, store.MarkNotificationSent(ctx, 9999))
+
+		changed, err := store.TryMarkNotificationError(ctx, sent)
+		require.NoError(t, err)
+		assert.False(t, changed, "already sent")
+		changed, err = store.TryMarkNotificationSent(ctx, 9999)
+		require.NoError(t, err)
+		assert.False(t, changed, "does not exist")
+		pending, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "pending"})
+		require.NoError(t, err)
+		changed, err = store.TryMarkNotificationSent(ctx, pending)
+		require.NoError(t, err)
+		assert.True(t, changed)
+
+		statuses := make(map[int]db.NotificationStatus)
+		require.NoError(t, store.IterateNotifications(ctx, func(n db.StoredNotification) error {
+			statuses[n.ID] = n.Status
+			return nil
+		}))
+		assert.Equal(t, db.NotificationStatusSent, statuses[sent])
+		assert.Equal(t, db.NotificationStatusError, statuses[failed])
+
+		listed, err := store.ListNotifications(ctx, db.NotificationFilter{Statuses: []db.NotificationStatus{db.NotificationStatusError}})
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, failed, listed[0].ID)
+	})
+
+	t.Run("counts by status", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		empty := map[db.NotificationStatus]int{
+			db.NotificationStatusInput: 0,
+			db.NotificationStatusSent:  0,
+			db.NotificationStatusError: 0,
+		}
+		counts, err := store.CountByStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, empty, counts)
+
+		insert := func(topic string) int {
+			id, err := store.InsertNotification(ctx, exchange.Notification{Topic: topic, Message: "message"})
+			require.NoError(t, err)
+			return id
+		}
+		insert("alerts")
+		require.NoError(t, store.MarkNotificationSent(ctx, insert("alerts")))
+		require.NoError(t, store.MarkNotificationSent(ctx, insert("builds")))
+		require.NoError(t, store.MarkNotificationError(ctx, insert("builds")))
+		require.NoError(t, store.SoftDeleteNotification(ctx, insert("builds")))
+
+		counts, err = store.CountByStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[db.NotificationStatus]int{
+			db.NotificationStatusInput: 1,
+			db.NotificationStatusSent:  2,
+			db.NotificationStatusError: 1,
+		}, counts)
+
+		counts, err = store.CountByTopic(ctx, "alerts")
+		require.NoError(t, err)
+		assert.Equal(t, map[db.NotificationStatus]int{
+			db.NotificationStatusInput: 1,
+			db.NotificationStatusSent:  1,
+			db.NotificationStatusError: 0,
+		}, counts)
+
+		counts, err = store.CountByTopic(ctx, "missing")
+		require.NoError(t, err)
+		assert.Equal(t, empty, counts)
+	})
+
+	t.Run("delivery history", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		sent, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "sent"})
+		require.NoError(t, err)
+		failing, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "failing"})
+		require.NoError(t, err)
+
+		got, err := store.GetNotification(ctx, sent)
+		require.NoError(t, err)
+		assert.Nil(t, got.SentAt, "fresh INPUT notification")
+		assert.Zero(t, got.ErrorCount)
+
+		before := time.Now().UTC().Add(-time.Second)
+		require.NoError(t, store.MarkNotificationSent(ctx, sent))
+		got, err = store.GetNotification(ctx, sent)
+		require.NoError(t, err)
+		require.NotNil(t, got.SentAt)
+		assert.False(t, got.SentAt.Before(before.Truncate(time.Second)), "sent_at = %v", got.SentAt)
+		assert.Zero(t, got.ErrorCount)
+
+		for range 2 {
+			require.NoError(t, store.MarkNotificationError(ctx, failing))
+			got, err = store.GetNotification(ctx, failing)
+			require.NoError(t, err)
+			assert.NotNil(t, got.SentAt, "ERROR records when it failed")
+			require.NoError(t, store.RequeueNotification(ctx, failing))
+		}
+
+		listed, err := store.ListNotifications(ctx, db.NotificationFilter{Topic: "alerts"})
+		require.NoError(t, err)
+		require.Len(t, listed, 2)
+		for _, n := range listed {
+			if n.ID == failing {
+				assert.Nil(t, n.SentAt, "requeued notifications are INPUT again")
+				assert.Equal(t, 2, n.ErrorCount)
+			} else {
+				assert.NotNil(t, n.SentAt)
+			}
+		}
+	})
+
+	t.Run("requeue", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		insert := func(message string) int {
+			id, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: message})
+			require.NoError(t, err)
+			return id
+		}
+		failed := insert("failed")
+		sent := insert("sent")
+		others := []int{insert("other 1"), insert("other 2"), insert("other 3")}
+		require.NoError(t, store.MarkNotificationError(ctx, failed))
+		require.NoError(t, store.MarkNotificationSent(ctx, sent))
+		for _, id := range others {
+			require.NoError(t, store.MarkNotificationError(ctx, id))
+		}
+
+		require.NoError(t, store.RequeueNotification(ctx, failed))
+		got, err := store.GetNotification(ctx, failed)
+		require.NoError(t, err)
+		assert.Equal(t, db.NotificationStatusInput, got.Status)
+
+		// Only ERROR notifications are requeued.
+		require.NoError(t, store.RequeueNotification(ctx, sent))
+		got, err = store.GetNotification(ctx, sent)
+		require.NoError(t, err)
+		assert.Equal(t, db.NotificationStatusSent, got.Status)
+
+		require.NoError(t, store.SoftDeleteNotification(ctx, others[2]))
+		n, err := store.RequeueAllErrors(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n, "deleted notifications stay in ERROR")
+
+		pending, err := store.GetPendingNotifications(ctx, 0)
+		require.NoError(t, err)
+		assert.Len(t, pending, 3)
+
+		n, err = store.RequeueAllErrors(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		first, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "first",
+			Metadata: map[string]string{"host": "a"}})
+		require.NoError(t, err)
+		second, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "second"})
+		require.NoError(t, err)
+
+		pending, err := store.GetPendingNotifications(ctx, 0)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+		assert.Equal(t, first, pending[0].ID, "oldest first")
+		assert.Equal(t, map[string]string{"host": "a"}, pending[0].Metadata)
+
+		pending, err = store.GetPendingNotifications(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, first, pending[0].ID)
+
+		require.NoError(t, store.MarkNotificationSent(ctx, first))
+		pending, err = store.GetPendingNotifications(ctx, 0)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, second, pending[0].ID)
+
+		got, err := store.GetNotification(ctx, first)
+		require.NoError(t, err)
+		assert.Equal(t, db.NotificationStatusSent, got.Status)
+		assert.Equal(t, "first", got.Message)
+
+		_, err = store.GetNotification(ctx, 9999)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("scheduled", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		now := time.Now()
+		future, err := store.InsertNotification(ctx, exchange.Notification{Topic: "reminders", Message: "later", SendAt: now.Add(time.Hour)})
+		require.NoError(t, err)
+		past, err := store.InsertNotification(ctx, exchange.Notification{Topic: "reminders", Message: "overdue", SendAt: now.Add(-time.Hour)})
+		require.NoError(t, err)
+		unscheduled, err := store.InsertNotification(ctx, exchange.Notification{Topic: "reminders", Message: "now"})
+		require.NoError(t, err)
+
+		due, err := store.GetDueNotifications(ctx, now, 0)
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		assert.Equal(t, past, due[0].ID)
+		require.NotNil(t, due[0].SendAt)
+		assert.WithinDuration(t, now.Add(-time.Hour), *due[0].SendAt, time.Second)
+		assert.Equal(t, unscheduled, due[1].ID)
+		assert.Nil(t, due[1].SendAt)
+
+		due, err = store.GetDueNotifications(ctx, now.Add(2*time.Hour), 0)
+		require.NoError(t, err)
+		assert.Len(t, due, 3)
+		assert.Equal(t, future, due[0].ID)
+
+		require.NoError(t, store.MarkNotificationSent(ctx, past))
+		due, err = store.GetDueNotifications(ctx, now, 1)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, unscheduled, due[0].ID)
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		sent, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "sent"})
+		require.NoError(t, err)
+		pending, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "pending"})
+		require.NoError(t, err)
+		require.NoError(t, store.MarkNotificationSent(ctx, sent))
+
+		n, err := store.DeleteNotificationsBefore(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		n, err = store.DeleteNotificationsBefore(ctx, time.Now().Add(time.Hour), db.NotificationStatusSent)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		_, err = store.GetNotification(ctx, sent)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		count, err := store.CountNotifications(ctx, db.NotificationFilter{IncludeDeleted: true})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		next, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "next"})
+		require.NoError(t, err)
+		assert.Greater(t, next, pending, "IDs are not reused")
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		store := newStore(t)
+		defer store.Close()
+
+		_, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "too high", Priority: db.MaxPriority + 1})
+		assert.ErrorIs(t, err, db.ErrInvalidPriority)
+		_, err = store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "negative", Priority: -1})
+		assert.ErrorIs(t, err, db.ErrInvalidPriority)
+
+		var ids []int
+		for _, priority := range []int{0, 5, 0, 9, 5} {
+			id, err := store.InsertNotification(ctx, exchange.Notification{Topic: "alerts", Message: "queued", Priority: priority})
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+
+		pending, err := store.GetPendingNotifications(ctx, 0)
+		require.NoError(t, err)
+		got := make([]int, 0, len(pending))
+		for _, n := range pending {
+			got = append(got, n.ID)
+		}
+		assert.Equal(t, []int{ids[3], ids[1], ids[4], ids[0], ids[2]}, got, "highest priority first, then oldest")
+		assert.Equal(t, 9, pending[0].Priority)
+	})
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownDevice = errors.New("device is not registered")
+
+// Subscribe makes deviceID receive notifications on topicName, creating the topic if it
+// does not exist yet. Subscribing again is a no-op.
+func (s *LibSQL) Subscribe(ctx context.Context, deviceID, topicName string) error {
+	if _, err := s.GetDevicePublicKey(ctx, deviceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to subscribe device %s: %w", deviceID, ErrUnknownDevice)
+		}
+		return err
+	}
+	topicID, err := s.GetOrCreateTopic(ctx, topicName, "")
+	if err != nil {
+		return fmt.Errorf("failed to get or create topic: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		"INSERT INTO subscriptions (device_id, topic_id) VALUES (?, ?) ON CONFLICT(device_id, topic_id) DO NOTHING",
+		deviceID, topicID); err != nil {
+		return fmt.Errorf("failed to insert subscription: %w", err)
+	}
+	return nil
+}
+
+// Unsubscribe removes the subscription of deviceID to topicName. Removing a missing
+// subscription is a no-op.
+func (s *LibSQL) Unsubscribe(ctx context.Context, deviceID, topicName string) error {
+	if _, err := s.db.ExecContext(ctx,
+		"DELETE FROM subscriptions WHERE device_id = ? AND topic_id IN (SELECT topic_id FROM topics WHERE topic_name = ?)",
+		deviceID, topicName); err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	return nil
+}
+
+// DevicesForTopic returns the devices subscribed to topicName, sorted by ID.
+func (s *LibSQL) DevicesForTopic(ctx context.Context, topicName string) ([]Device, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT d.device_id, d.public_key, COALESCE(d.signing_key, ''), COALESCE(d.endpoint, ''), COALESCE(d.locale, ''), d.registration_date
+		FROM subscriptions s
+		JOIN topics t ON t.topic_id = s.topic_id
+		JOIN devices d ON d.device_id = s.device_id
+		WHERE t.topic_name = ?
+		ORDER BY d.device_id`, topicName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscriptions: %w", err)
+	}
+	return scanDevices(rows)
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DescriptionMetadataKey is the metadata key InsertNotification reads topic descriptions from.
+const DescriptionMetadataKey = "description"
+
+// TopicDescriptionPolicy decides what InsertNotification does with a description carried
+// in a notification's metadata.
+type TopicDescriptionPolicy int
+
+const (
+	// DescriptionIgnore never touches topic descriptions.
+	DescriptionIgnore TopicDescriptionPolicy = iota
+	// DescriptionSetIfEmpty only fills in a description the topic does not have yet.
+	DescriptionSetIfEmpty
+	// DescriptionLastWriterWins overwrites the description with the latest one sent.
+	DescriptionLastWriterWins
+)
+
+// WithTopicDescriptionPolicy sets how descriptions from notification metadata are applied
+// to their topic. The default is DescriptionIgnore.
+func WithTopicDescriptionPolicy(policy TopicDescriptionPolicy) Option {
+	return func(s *LibSQL) {
+		s.descriptionPolicy = policy
+	}
+}
+
+// applyTopicDescription updates the description of topicID according to the configured
+// policy. Writes that would not change the stored value are skipped.
+func (s *LibSQL) applyTopicDescription(ctx context.Context, tx *sql.Tx, topicID int, description string) error {
+	if description == "" {
+		return nil
+	}
+
+	var query string
+	switch s.descriptionPolicy {
+	case DescriptionSetIfEmpty:
+		query = "UPDATE topics SET description = ? WHERE topic_id = ? AND (description IS NULL OR description = '')"
+	case DescriptionLastWriterWins:
+		query = "UPDATE topics SET description = ? WHERE topic_id = ? AND description IS NOT ?"
+	default:
+		return nil
+	}
+
+	args := []any{description, topicID}
+	if s.descriptionPolicy == DescriptionLastWriterWins {
+		args = append(args, description)
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to update topic description: %w", err)
+	}
+	return nil
+}
+
+// TopicDescription returns the stored description of a topic, or an empty string if it has none.
+func (s *LibSQL) TopicDescription(ctx context.Context, topicName string) (string, error) {
+	var description sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT description FROM topics WHERE topic_name = ?", topicName).Scan(&description)
+	if err != nil {
+		return "", fmt.Errorf("failed to get topic description: %w", err)
+	}
+	return description.String, nil
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+)
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the authenticated user. Only authentication
+// middleware should call it, Actor trusts whatever it finds.
+func WithIdentity(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, identityKey{}, user)
+}
+
+// Identity returns the user the request was authenticated as, if any.
+func Identity(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(identityKey{}).(string)
+	return user, ok && user != ""
+}
+
+// BasicAuth rejects requests with 401 unless check accepts their basic auth credentials or
+// an outer middleware already authenticated them. check should compare in constant time.
+func BasicAuth(check func(user, password string) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := Identity(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, password, ok := r.BasicAuth()
+			if !ok || user == "" || !check(user, password) {
+				slog.Warn("Rejecting unauthenticated request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", `Basic realm="cland"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), user)))
+		})
+	}
+}
+
+// TrustedProxy takes the identity from the X-Forwarded-User header of requests coming from
+// one of proxies, an authenticating reverse proxy. The header is ignored for everyone else.
+func TrustedProxy(proxies ...netip.Addr) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := r.Header.Get("X-Forwarded-User")
+			if user == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			remote, err := netip.ParseAddrPort(r.RemoteAddr)
+			if err != nil || !slices.Contains(proxies, remote.Addr().Unmap()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), user)))
+		})
+	}
+}
@@ -1 +1,83 @@
 package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/dikkadev/cland/internal/db"
+)
+
+type AuditRecorder interface {
+	RecordAuditEntry(ctx context.Context, entry db.AuditEntry) error
+}
+
+type auditTargetKey struct{}
+
+// SetAuditTarget names the object a request acted on, e.g. a device ID. Handlers call it so
+// the audit middleware can record the target. It is a no-op outside of Audit.
+func SetAuditTarget(ctx context.Context, target string) {
+	if t, ok := ctx.Value(auditTargetKey{}).(*string); ok {
+		*t = target
+	}
+}
+
+// Actor identifies who made the request: the identity an authentication middleware such as
+// BasicAuth or TrustedProxy put into its context, or "anonymous". Credentials and headers
+// of the request itself are never trusted here.
+func Actor(r *http.Request) string {
+	if user, ok := Identity(r.Context()); ok {
+		return user
+	}
+	return "anonymous"
+}
+
+// Audit records every mutating request passing through next. Read-only requests are not
+// recorded. Failing to record is logged but does not fail the request, it already happened.
+func Audit(recorder AuditRecorder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !mutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var target string
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditTargetKey{}, &target)))
+
+			outcome := db.AuditOutcomeSuccess
+			if rec.status >= 400 {
+				outcome = db.AuditOutcomeFailure
+			}
+			entry := db.AuditEntry{
+				Actor:   Actor(r),
+				Action:  r.Method + " " + r.URL.Path,
+				Target:  target,
+				Status:  rec.status,
+				Outcome: outcome,
+			}
+			if err := recorder.RecordAuditEntry(context.WithoutCancel(r.Context()), entry); err != nil {
+				slog.Error("Error recording audit entry", "action", entry.Action, "actor", entry.Actor, "err", err)
+			}
+		})
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+)
+
+// RuleSource provides the silence rules and when each topic was last heard from.
+type RuleSource interface {
+	ListSilenceRules(ctx context.Context) ([]db.SilenceRule, error)
+	LastNotificationTimes(ctx context.Context) (map[string]time.Time, error)
+}
+
+// SilenceChecker is a dead man's switch: it periodically compares every silence rule with
+// the newest notification of its topic and inserts an alert on Topic once a topic has been
+// quiet for longer than its interval. A silent topic is alerted once, it is re-armed as soon
+// as a new notification arrives.
+type SilenceChecker struct {
+	Topic    string
+	Interval time.Duration
+
+	rules RuleSource
+	store exchange.Store
+
+	now       func() time.Time
+	newTicker func(time.Duration) (<-chan time.Time, func())
+
+	mu      sync.Mutex
+	started time.Time
+	alerted map[string]time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewSilenceChecker checks the rules every interval and sends alerts to topic.
+func NewSilenceChecker(rules RuleSource, store exchange.Store, topic string, interval time.Duration) *SilenceChecker {
+	return &SilenceChecker{
+		Topic:    topic,
+		Interval: interval,
+		rules:    rules,
+		store:    store,
+		now:      time.Now,
+		newTicker: func(d time.Duration) (<-chan time.Time, func()) {
+			t := time.NewTicker(d)
+			return t.C, t.Stop
+		},
+		alerted: make(map[string]time.Time),
+	}
+}
+
+func (c *SilenceChecker) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		return
+	}
+
+	slog.Info("Starting silence checker", "topic", c.Topic, "interval", c.Interval)
+	ctx, cancel := context.WithCancel(context.Background())
+	tick, stopTicker := c.newTicker(c.Interval)
+	c.started = c.now()
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		defer stopTicker()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+				if err := c.check(ctx); err != nil {
+					slog.Error("Error checking silence rules", "err", err)
+				}
+			}
+		}
+	}(c.done)
+}
+
+// Stop halts the checker and waits for a running check to return.
+func (c *SilenceChecker) Stop() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel = nil
+	c.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+	slog.Info("Silence checker stopped", "topic", c.Topic)
+}
+
+func (c *SilenceChecker) check(ctx context.Context) error {
+	rules, err := c.rules.ListSilenceRules(ctx)
+	if err != nil {
+		return err
+	}
+	last, err := c.rules.LastNotificationTimes(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	for _, rule := range rules {
+		// Topics that never sent anything are measured from when the checker started.
+		seen, ok := last[rule.Topic]
+		if !ok {
+			seen = c.started
+		}
+		if now.Sub(seen) <= rule.Interval {
+			continue
+		}
+		if alertedFor, ok := c.alerted[rule.Topic]; ok && alertedFor.Equal(seen) {
+			continue
+		}
+
+		slog.Warn("Topic went silent", "topic", rule.Topic, "interval", rule.Interval, "last", seen)
+		_, err := c.store.InsertNotification(ctx, exchange.Notification{
+			Topic: c.Topic,
+			Metadata: map[string]string{
+				"silent_topic": rule.Topic,
+				"interval":     rule.Interval.String(),
+			},
+			Message: fmt.Sprintf("No notification on %s for %s", rule.Topic, now.Sub(seen).Round(time.Second)),
+		})
+		if err != nil {
+			slog.Error("Error inserting silence alert", "topic", rule.Topic, "err", err)
+			continue
+		}
+		c.alerted[rule.Topic] = seen
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilentTopicTriggersAlert(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.NewLibSQL("file::memory:?cache=shared")
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, database.Initialize(ctx))
+
+	require.NoError(t, database.SetSilenceRule(ctx, db.SilenceRule{Topic: "backups", Interval: time.Hour}))
+	require.NoError(t, database.SetSilenceRule(ctx, db.SilenceRule{Topic: "never", Interval: 2 * time.Hour}))
+	_, err = database.InsertNotification(ctx, exchange.Notification{Topic: "backups", Message: "backup done"})
+	require.NoError(t, err)
+
+	start := time.Now()
+	now := start
+	checker := NewSilenceChecker(database, database, "cland.alerts", time.Minute)
+	checker.now = func() time.Time { return now }
+	checker.started = start
+
+	alerts := func() []db.StoredNotification {
+		t.Helper()
+		var found []db.StoredNotification
+		require.NoError(t, database.IterateNotifications(ctx, func(n db.StoredNotification) error {
+			if n.Topic == "cland.alerts" {
+				found = append(found, n)
+			}
+			return nil
+		}))
+		return found
+	}
+
+	now = start.Add(30 * time.Minute)
+	require.NoError(t, checker.check(ctx))
+	assert.Empty(t, alerts(), "alert before the interval passed")
+
+	now = start.Add(90 * time.Minute)
+	require.NoError(t, checker.check(ctx))
+	got := alerts()
+	require.Len(t, got, 1)
+	assert.Equal(t, "backups", got[0].Metadata["silent_topic"])
+
+	// Still silent, but already alerted.
+	now = start.Add(100 * time.Minute)
+	require.NoError(t, checker.check(ctx))
+	assert.Len(t, alerts(), 1)
+
+	// A topic that never sent anything counts from when the checker started.
+	now = start.Add(3 * time.Hour)
+	require.NoError(t, checker.check(ctx))
+	got = alerts()
+	require.Len(t, got, 2)
+	assert.Equal(t, "never", got[1].Metadata["silent_topic"])
+}
@@ -0,0 +1,62 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type StatusMarker interface {
+	MarkNotificationSent(ctx context.Context, notificationID int) error
+	MarkNotificationError(ctx context.Context, notificationID int) error
+}
+
+// Dispatcher delivers a single notification, records the outcome and measures the time
+// from ingestion to delivery.
+type Dispatcher struct {
+	sender   db.Sender
+	statuses StatusMarker
+	latency  *prometheus.HistogramVec
+	now      func() time.Time
+}
+
+// NewDispatcher registers the delivery latency histogram with reg unless it is nil.
+func NewDispatcher(sender db.Sender, statuses StatusMarker, reg prometheus.Registerer) (*Dispatcher, error) {
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cland",
+		Name:      "delivery_latency_seconds",
+		Help:      "Time from a notification being ingested to it being marked as sent.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 3600},
+	}, []string{"topic"})
+	if reg != nil {
+		if err := reg.Register(latency); err != nil {
+			return nil, fmt.Errorf("failed to register delivery latency histogram: %w", err)
+		}
+	}
+
+	return &Dispatcher{
+		sender:   sender,
+		statuses: statuses,
+		latency:  latency,
+		now:      time.Now,
+	}, nil
+}
+
+func (d *Dispatcher) Dispatch(ctx context.Context, notif db.StoredNotification) error {
+	if sendErr := d.sender.Send(ctx, notif); sendErr != nil {
+		if err := d.statuses.MarkNotificationError(ctx, notif.ID); err != nil {
+			return fmt.Errorf("failed to mark notification %d as error after %w: %w", notif.ID, sendErr, err)
+		}
+		return sendErr
+	}
+
+	if err := d.statuses.MarkNotificationSent(ctx, notif.ID); err != nil {
+		return fmt.Errorf("failed to mark notification %d as sent: %w", notif.ID, err)
+	}
+	sentAt := d.now()
+	d.latency.WithLabelValues(notif.Topic).Observe(sentAt.Sub(notif.Timestamp).Seconds())
+	return nil
+}
@@ -0,0 +1,90 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSender struct {
+	err error
+}
+
+func (s *fakeSender) Send(ctx context.Context, notif db.StoredNotification) error {
+	return s.err
+}
+
+type fakeStatuses struct {
+	sent   []int
+	errors []int
+}
+
+func (s *fakeStatuses) MarkNotificationSent(ctx context.Context, id int) error {
+	s.sent = append(s.sent, id)
+	return nil
+}
+
+func (s *fakeStatuses) MarkNotificationError(ctx context.Context, id int) error {
+	s.errors = append(s.errors, id)
+	return nil
+}
+
+func latencySample(t *testing.T, reg *prometheus.Registry, topic string) (uint64, float64) {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "cland_delivery_latency_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "topic" && label.GetValue() == topic {
+					return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+	return 0, 0
+}
+
+func TestDispatchRecordsLatency(t *testing.T) {
+	ingested := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	reg := prometheus.NewRegistry()
+	statuses := &fakeStatuses{}
+
+	d, err := NewDispatcher(&fakeSender{}, statuses, reg)
+	require.NoError(t, err)
+	d.now = func() time.Time { return ingested.Add(90 * time.Second) }
+
+	err = d.Dispatch(context.Background(), db.StoredNotification{ID: 1, Topic: "alerts", Timestamp: ingested})
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{1}, statuses.sent)
+	count, sum := latencySample(t, reg, "alerts")
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 90.0, sum)
+}
+
+func TestDispatchFailureIsNotObserved(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	statuses := &fakeStatuses{}
+	sendErr := errors.New("endpoint down")
+
+	d, err := NewDispatcher(&fakeSender{err: sendErr}, statuses, reg)
+	require.NoError(t, err)
+
+	err = d.Dispatch(context.Background(), db.StoredNotification{ID: 2, Topic: "alerts", Timestamp: time.Now()})
+	assert.ErrorIs(t, err, sendErr)
+
+	assert.Empty(t, statuses.sent)
+	assert.Equal(t, []int{2}, statuses.errors)
+	count, _ := latencySample(t, reg, "alerts")
+	assert.Equal(t, uint64(0), count)
+}
@@ -0,0 +1,86 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const saltLength = 16
+
+// encrypt seals payload for a device's P-256 public key (the uncompressed point, base64url
+// encoded as browsers expose it). An ephemeral ECDH key agreement is run against the device
+// key and AES-128-GCM key and nonce are derived from the shared secret with HKDF-SHA256.
+//
+// The result is laid out as ephemeral public key (65 bytes) | salt (16 bytes) | ciphertext.
+func encrypt(publicKey string, payload []byte) ([]byte, error) {
+	raw, err := decodeKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	devicePub, err := ecdh.P256().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	secret, err := ephemeral.ECDH(devicePub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive shared secret: %w", err)
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, nonce, err := deriveCipher(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append(ephemeral.PublicKey().Bytes(), salt...)
+	return gcm.Seal(out, nonce, payload, nil), nil
+}
+
+func deriveCipher(secret, salt []byte) (cipher.AEAD, []byte, error) {
+	key := hkdf(salt, secret, []byte("cland aes128gcm key"), 16)
+	nonce := hkdf(salt, secret, []byte("cland aes128gcm nonce"), 12)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nonce, nil
+}
+
+// hkdf implements RFC 5869 for outputs of at most one SHA-256 block.
+func hkdf(salt, secret, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
+
+func decodeKey(key string) ([]byte, error) {
+	key = strings.TrimRight(key, "=")
+	key = strings.NewReplacer("+", "-", "/", "_").Replace(key)
+	return base64.RawURLEncoding.DecodeString(key)
+}
@@ -0,0 +1,42 @@
+package push
+
+import (
+	"strings"
+
+	"github.com/dikkadev/cland/internal/db"
+)
+
+// LocalizedMessagePrefix prefixes metadata keys holding a translated message, e.g.
+// "message.de" or "message.pt-BR".
+const LocalizedMessagePrefix = "message."
+
+// localizedMessage picks the message variant for locale. An exact match wins over the base
+// language ("de-AT" falls back to "de"), and without any variant the default message is used.
+// Locales are compared case-insensitively and "_" is treated like "-".
+func localizedMessage(notif db.StoredNotification, locale string) string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return notif.Message
+	}
+
+	variants := make(map[string]string)
+	for key, value := range notif.Metadata {
+		if tag, ok := strings.CutPrefix(key, LocalizedMessagePrefix); ok && value != "" {
+			variants[normalizeLocale(tag)] = value
+		}
+	}
+
+	if msg, ok := variants[locale]; ok {
+		return msg
+	}
+	if base, _, ok := strings.Cut(locale, "-"); ok {
+		if msg, ok := variants[base]; ok {
+			return msg
+		}
+	}
+	return notif.Message
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,76 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalizedMessage(t *testing.T) {
+	notif := db.StoredNotification{
+		Message: "disk full",
+		Metadata: map[string]string{
+			"message.de":    "Festplatte voll",
+			"message.pt_BR": "disco cheio",
+			"message.fr":    "",
+		},
+	}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "", want: "disk full"},
+		{locale: "de", want: "Festplatte voll"},
+		{locale: "de-AT", want: "Festplatte voll"},
+		{locale: "DE_at", want: "Festplatte voll"},
+		{locale: "pt-BR", want: "disco cheio"},
+		{locale: "pt", want: "disk full"},
+		{locale: "fr", want: "disk full"},
+		{locale: "ja", want: "disk full"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			assert.Equal(t, tt.want, localizedMessage(notif, tt.locale))
+		})
+	}
+}
+
+func TestSenderLocalizesPerDevice(t *testing.T) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pub := base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes())
+
+	german, english := &target{}, &target{}
+	germanServer, englishServer := httptest.NewServer(german), httptest.NewServer(english)
+	defer germanServer.Close()
+	defer englishServer.Close()
+
+	sender := NewSender(subscribers{"alerts": {
+		{ID: "de", PublicKey: pub, Endpoint: germanServer.URL, Locale: "de-DE"},
+		{ID: "en", PublicKey: pub, Endpoint: englishServer.URL, Locale: "en"},
+	}}, nil)
+
+	err = sender.Send(context.Background(), db.StoredNotification{
+		ID:       1,
+		Topic:    "alerts",
+		Message:  "disk full",
+		Metadata: map[string]string{"message.de": "Festplatte voll"},
+	})
+	require.NoError(t, err)
+
+	for tg, want := range map[*target]string{german: "Festplatte voll", english: "disk full"} {
+		require.Len(t, tg.bodies, 1)
+		var got Payload
+		require.NoError(t, json.Unmarshal(decrypt(t, priv, tg.bodies[0]), &got))
+		assert.Equal(t, want, got.Message)
+	}
+}
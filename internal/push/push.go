@@ -1 +1,112 @@
 package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+)
+
+type Subscribers interface {
+	DevicesForTopic(ctx context.Context, topicName string) ([]db.Device, error)
+}
+
+type Payload struct {
+	ID        int               `json:"id"`
+	Topic     string            `json:"topic"`
+	Timestamp time.Time         `json:"timestamp"`
+	Message   string            `json:"message"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// Sender delivers notifications to the devices subscribed to their topic by POSTing the
+// payload encrypted to the device's public key. There is no fallback, a topic nobody
+// subscribed to reaches no device, and subscribed devices without an endpoint are skipped.
+type Sender struct {
+	devices Subscribers
+	client  *http.Client
+}
+
+var (
+	_ db.Sender          = (*Sender)(nil)
+	_ exchange.Deliverer = (*Sender)(nil)
+)
+
+func NewSender(devices Subscribers, client *http.Client) *Sender {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Sender{devices: devices, client: client}
+}
+
+func (s *Sender) Send(ctx context.Context, notif db.StoredNotification) error {
+	devices, err := s.devices.DevicesForTopic(ctx, notif.Topic)
+	if err != nil {
+		return fmt.Errorf("failed to get subscribers of topic %s: %w", notif.Topic, err)
+	}
+
+	var errs []error
+	for _, device := range devices {
+		if device.Endpoint == "" {
+			continue
+		}
+		payload, err := json.Marshal(Payload{
+			ID:        notif.ID,
+			Topic:     notif.Topic,
+			Timestamp: notif.Timestamp,
+			Message:   localizedMessage(notif, device.Locale),
+			Metadata:  notif.Metadata,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		if err := s.deliver(ctx, device, payload); err != nil {
+			slog.Error("Error delivering notification", "notification", notif.ID, "device", device.ID, "err", err)
+			errs = append(errs, fmt.Errorf("device %s: %w", device.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Deliver sends a notification the handler just stored.
+func (s *Sender) Deliver(ctx context.Context, notif exchange.Notification) error {
+	return s.Send(ctx, db.StoredNotification{
+		ID:        notif.ID(),
+		Topic:     notif.Topic,
+		Timestamp: time.Now().UTC(),
+		Status:    db.NotificationStatusInput,
+		Message:   notif.Message,
+		Metadata:  notif.Metadata,
+	})
+}
+
+func (s *Sender) deliver(ctx context.Context, device db.Device, payload []byte) error {
+	body, err := encrypt(device.PublicKey, payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, device.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/dikkadev/cland/pkg/exchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribers maps topic names to the devices subscribed to them.
+type subscribers map[string][]db.Device
+
+func (s subscribers) DevicesForTopic(ctx context.Context, topicName string) ([]db.Device, error) {
+	return s[topicName], nil
+}
+
+func decrypt(t *testing.T, priv *ecdh.PrivateKey, body []byte) []byte {
+	t.Helper()
+	require.Greater(t, len(body), 65+saltLength)
+
+	ephemeral, err := ecdh.P256().NewPublicKey(body[:65])
+	require.NoError(t, err)
+	secret, err := priv.ECDH(ephemeral)
+	require.NoError(t, err)
+
+	gcm, nonce, err := deriveCipher(secret, body[65:65+saltLength])
+	require.NoError(t, err)
+	plain, err := gcm.Open(nil, nonce, body[65+saltLength:], nil)
+	require.NoError(t, err)
+	return plain
+}
+
+type target struct {
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func (tg *target) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	tg.mu.Lock()
+	tg.bodies = append(tg.bodies, body)
+	tg.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+func TestSenderDeliversToEndpoints(t *testing.T) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tg := &target{}
+	server := httptest.NewServer(tg)
+	defer server.Close()
+
+	sender := NewSender(subscribers{"alerts": {
+		{ID: "phone", PublicKey: base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes()), Endpoint: server.URL + "/phone"},
+		{ID: "no-endpoint", PublicKey: "unused"},
+	}}, server.Client())
+
+	notif := db.StoredNotification{
+		ID:        7,
+		Topic:     "alerts",
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Message:   "disk full",
+		Metadata:  map[string]string{"host": "a"},
+	}
+	require.NoError(t, sender.Send(context.Background(), notif))
+
+	require.Len(t, tg.bodies, 1)
+	var got Payload
+	require.NoError(t, json.Unmarshal(decrypt(t, priv, tg.bodies[0]), &got))
+	assert.Equal(t, Payload{
+		ID:        7,
+		Topic:     "alerts",
+		Timestamp: notif.Timestamp,
+		Message:   "disk full",
+		Metadata:  map[string]string{"host": "a"},
+	}, got)
+}
+
+func TestSenderReportsFailures(t *testing.T) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pub := base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes())
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer failing.Close()
+
+	tg := &target{}
+	ok := httptest.NewServer(tg)
+	defer ok.Close()
+
+	sender := NewSender(subscribers{"t": {
+		{ID: "gone", PublicKey: pub, Endpoint: failing.URL},
+		{ID: "bad-key", PublicKey: "not a key", Endpoint: ok.URL},
+		{ID: "ok", PublicKey: pub, Endpoint: ok.URL},
+	}}, nil)
+
+	err = sender.Send(context.Background(), db.StoredNotification{ID: 1, Topic: "t", Message: "m"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "device gone")
+	assert.Contains(t, err.Error(), "device bad-key")
+	assert.NotContains(t, err.Error(), "device ok")
+	assert.Len(t, tg.bodies, 1)
+}
+
+func TestSenderOnlyDeliversToSubscribers(t *testing.T) {
+	ctx := context.Background()
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pub := base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes())
+
+	subscribed, unsubscribed := &target{}, &target{}
+	subscribedServer, unsubscribedServer := httptest.NewServer(subscribed), httptest.NewServer(unsubscribed)
+	defer subscribedServer.Close()
+	defer unsubscribedServer.Close()
+
+	store := db.NewMemory()
+	defer store.Close()
+	require.NoError(t, store.RegisterDevice(ctx, db.Device{ID: "ops", PublicKey: pub, Endpoint: subscribedServer.URL}))
+	require.NoError(t, store.RegisterDevice(ctx, db.Device{ID: "dev", PublicKey: pub, Endpoint: unsubscribedServer.URL}))
+	require.NoError(t, store.Subscribe(ctx, "ops", "alerts"))
+	require.NoError(t, store.Subscribe(ctx, "dev", "builds"))
+
+	sender := NewSender(store, nil)
+	require.NoError(t, sender.Send(ctx, db.StoredNotification{ID: 1, Topic: "alerts", Message: "disk full"}))
+	require.NoError(t, sender.Deliver(ctx, exchange.Notification{Topic: "alerts", Message: "disk full again"}))
+	require.NoError(t, sender.Send(ctx, db.StoredNotification{ID: 2, Topic: "nobody", Message: "dropped"}))
+
+	assert.Len(t, subscribed.bodies, 2)
+	assert.Empty(t, unsubscribed.bodies)
+}
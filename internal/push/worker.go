@@ -0,0 +1,114 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/dikkadev/cland/internal/db"
+)
+
+type dispatcher interface {
+	Dispatch(ctx context.Context, notif db.StoredNotification) error
+}
+
+// Worker delivers batches of notifications concurrently.
+type Worker struct {
+	dispatcher  dispatcher
+	concurrency int
+	ordered     bool
+}
+
+type WorkerOption func(*Worker)
+
+// WithConcurrency limits how many deliveries run at the same time. The default is 4.
+func WithConcurrency(n int) WorkerOption {
+	return func(w *Worker) {
+		if n > 0 {
+			w.concurrency = n
+		}
+	}
+}
+
+// WithTopicOrdering delivers notifications of the same topic one after another in timestamp
+// order, so a follow-up never overtakes the notification it refers to. Different topics are
+// still delivered concurrently.
+func WithTopicOrdering() WorkerOption {
+	return func(w *Worker) {
+		w.ordered = true
+	}
+}
+
+func NewWorker(d dispatcher, opts ...WorkerOption) *Worker {
+	w := &Worker{dispatcher: d, concurrency: 4}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Deliver dispatches every notification of batch and returns once all are done. Failures do
+// not stop the rest of the batch, they are joined into the returned error.
+func (w *Worker) Deliver(ctx context.Context, batch []db.StoredNotification) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, w.concurrency)
+	)
+
+	for _, queue := range w.queues(batch) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(queue []db.StoredNotification) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for _, notif := range queue {
+				if err := w.dispatcher.Dispatch(ctx, notif); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}(queue)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// queues splits batch into independently deliverable queues. Without topic ordering every
+// notification is its own queue, with it there is one queue per topic sorted by timestamp.
+func (w *Worker) queues(batch []db.StoredNotification) [][]db.StoredNotification {
+	if !w.ordered {
+		queues := make([][]db.StoredNotification, 0, len(batch))
+		for _, notif := range batch {
+			queues = append(queues, []db.StoredNotification{notif})
+		}
+		return queues
+	}
+
+	byTopic := make(map[string]int)
+	queues := make([][]db.StoredNotification, 0)
+	for _, notif := range batch {
+		i, ok := byTopic[notif.Topic]
+		if !ok {
+			i = len(queues)
+			byTopic[notif.Topic] = i
+			queues = append(queues, nil)
+		}
+		queues[i] = append(queues[i], notif)
+	}
+	for _, queue := range queues {
+		sort.SliceStable(queue, func(i, j int) bool {
+			if !queue[i].Timestamp.Equal(queue[j].Timestamp) {
+				return queue[i].Timestamp.Before(queue[j].Timestamp)
+			}
+			return queue[i].ID < queue[j].ID
+		})
+	}
+	return queues
+}
@@ -0,0 +1,68 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/cland/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingDispatcher struct {
+	mu    sync.Mutex
+	order map[string][]int
+	fail  map[int]bool
+}
+
+func (d *recordingDispatcher) Dispatch(ctx context.Context, notif db.StoredNotification) error {
+	// Random latency makes reordering likely if deliveries of a topic overlap.
+	time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.order[notif.Topic] = append(d.order[notif.Topic], notif.ID)
+	if d.fail[notif.ID] {
+		return fmt.Errorf("notification %d failed", notif.ID)
+	}
+	return nil
+}
+
+func TestWorkerTopicOrdering(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	batch := make([]db.StoredNotification, 0)
+	for i := 1; i <= 20; i++ {
+		for _, topic := range []string{"a", "b"} {
+			batch = append(batch, db.StoredNotification{ID: i, Topic: topic, Timestamp: base.Add(time.Duration(i) * time.Second)})
+		}
+	}
+	rand.Shuffle(len(batch), func(i, j int) { batch[i], batch[j] = batch[j], batch[i] })
+
+	d := &recordingDispatcher{order: make(map[string][]int)}
+	w := NewWorker(d, WithConcurrency(8), WithTopicOrdering())
+	require.NoError(t, w.Deliver(context.Background(), batch))
+
+	want := make([]int, 0, 20)
+	for i := 1; i <= 20; i++ {
+		want = append(want, i)
+	}
+	assert.Equal(t, want, d.order["a"])
+	assert.Equal(t, want, d.order["b"])
+}
+
+func TestWorkerJoinsErrors(t *testing.T) {
+	d := &recordingDispatcher{order: make(map[string][]int), fail: map[int]bool{2: true, 3: true}}
+	w := NewWorker(d)
+
+	err := w.Deliver(context.Background(), []db.StoredNotification{
+		{ID: 1, Topic: "t"}, {ID: 2, Topic: "t"}, {ID: 3, Topic: "u"}, {ID: 4, Topic: "u"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "notification 2 failed")
+	assert.Contains(t, err.Error(), "notification 3 failed")
+	assert.Len(t, d.order["t"], 2)
+	assert.Len(t, d.order["u"], 2)
+}
@@ -0,0 +1,62 @@
+package exchange
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+)
+
+// WithMetadataAllowlist drops every metadata key not in keys. Without it all keys are kept.
+func WithMetadataAllowlist(keys ...string) Option {
+	return func(h *Handler) {
+		h.metadataAllowlist = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			h.metadataAllowlist[key] = true
+		}
+	}
+}
+
+// WithMetadataExtraKey keeps keys rejected by the allowlist by moving them into a single
+// JSON object stored under key instead of dropping them.
+func WithMetadataExtraKey(key string) Option {
+	return func(h *Handler) {
+		h.metadataExtraKey = key
+	}
+}
+
+func (h *Handler) applyMetadataAllowlist(notif *Notification, path string) {
+	if h.metadataAllowlist == nil {
+		return
+	}
+
+	extra := make(map[string]string)
+	for key, value := range notif.Metadata {
+		if h.metadataAllowlist[key] {
+			continue
+		}
+		extra[key] = value
+		delete(notif.Metadata, key)
+	}
+	if len(extra) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if h.metadataExtraKey == "" {
+		slog.Warn("Dropping metadata keys not on the allowlist", "file", path, "keys", keys)
+		return
+	}
+
+	blob, err := json.Marshal(extra)
+	if err != nil {
+		slog.Error("Error marshalling extra metadata, dropping keys", "file", path, "keys", keys, "err", err)
+		return
+	}
+	slog.Warn("Moving metadata keys not on the allowlist", "file", path, "keys", keys, "to", h.metadataExtraKey)
+	notif.Metadata[h.metadataExtraKey] = string(blob)
+}
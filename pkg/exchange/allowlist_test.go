@@ -0,0 +1,64 @@
+package exchange
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetadataAllowlist(t *testing.T) {
+	metadata := func() map[string]string {
+		return map[string]string{
+			"host":     "a",
+			"priority": "high",
+			"trace":    "123",
+			"user":     "bob",
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]string
+	}{
+		{
+			name: "no allowlist keeps everything",
+			want: metadata(),
+		},
+		{
+			name: "disallowed keys are dropped",
+			opts: []Option{WithMetadataAllowlist("host", "priority")},
+			want: map[string]string{
+				"host":     "a",
+				"priority": "high",
+			},
+		},
+		{
+			name: "disallowed keys are relocated",
+			opts: []Option{WithMetadataAllowlist("host", "priority"), WithMetadataExtraKey("extra")},
+			want: map[string]string{
+				"host":     "a",
+				"priority": "high",
+				"extra":    `{"trace":"123","user":"bob"}`,
+			},
+		},
+		{
+			name: "nothing to relocate",
+			opts: []Option{WithMetadataAllowlist("host", "priority", "trace", "user"), WithMetadataExtraKey("extra")},
+			want: metadata(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{}
+			for _, opt := range tt.opts {
+				opt(h)
+			}
+
+			notif := &Notification{Topic: "topic", Metadata: metadata(), Message: "message"}
+			h.applyMetadataAllowlist(notif, "input/file.txt")
+			if !reflect.DeepEqual(notif.Metadata, tt.want) {
+				t.Errorf("metadata = %v, want %v", notif.Metadata, tt.want)
+			}
+		})
+	}
+}
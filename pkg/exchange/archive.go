@@ -0,0 +1,220 @@
+package exchange
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DEFAULT_MAX_ARCHIVE_SIZE bounds the total uncompressed size of all entries of an archive.
+const DEFAULT_MAX_ARCHIVE_SIZE = 64 << 20
+
+var ErrArchiveTooLarge = errors.New("archive exceeds the maximum uncompressed size")
+
+// WithMaxArchiveSize overrides the limit on the total uncompressed size of an archive.
+func WithMaxArchiveSize(size int64) Option {
+	return func(h *Handler) {
+		h.maxArchiveSize = size
+	}
+}
+
+type archiveEntry struct {
+	name    string
+	content []byte
+}
+
+func isArchive(path string) bool {
+	name := strings.ToLower(path)
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// processArchive parses every entry of the archive as its own notification. The archive is
+// only handled if all entries parse, otherwise the errors of all failing entries are returned
+// and the archive is treated as failed as a whole.
+func (h *Handler) processArchive(ctx context.Context, proc *Process) error {
+	entries, err := h.readArchive(proc.Filepath)
+	if err != nil {
+		h.emit(proc.Filepath, EventParseError, err)
+		return err
+	}
+
+	procs := make([]*Process, 0, len(entries))
+	var errs []error
+	for _, entry := range entries {
+		p := &Process{Filepath: filepath.Join(proc.Filepath, entry.name), Options: proc.Options}
+		if err := p.parseContent(entry.content); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := h.verifySignature(ctx, p.Notif); err != nil {
+			setErrorFile(err, p.Filepath)
+			errs = append(errs, err)
+			continue
+		}
+		h.enrich(p)
+		if err := h.applyDirConfig(p.Notif, filepath.Dir(proc.Filepath)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := h.runStages(p.Notif); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		procs = append(procs, p)
+	}
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		h.emit(proc.Filepath, EventParseError, err)
+		return err
+	}
+	h.emit(proc.Filepath, EventParsed, nil)
+
+	// Inserts are not transactional across entries, an insert failure leaves the entries
+	// before it stored while the archive itself is moved to the error dir.
+	stored := make([]*Process, 0, len(procs))
+	for _, p := range procs {
+		err := h.persist(ctx, p.Notif)
+		if errors.Is(err, ErrDuplicateNotification) {
+			slog.Info("Skipping duplicate notification", "archive", proc.Filepath, "entry", p.Filepath)
+			continue
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to insert %s: %w", p.Filepath, err)
+			h.emit(proc.Filepath, EventStoreError, err)
+			return err
+		}
+		stored = append(stored, p)
+		h.processed.Add(1)
+		h.remember(p.Notif)
+		slog.Info("Notification parsed", "archive", proc.Filepath, "entry", p.Filepath, "topic", p.Notif.Topic)
+	}
+	h.consume(proc.Filepath)
+	for _, p := range stored {
+		h.deliver(ctx, p.Notif)
+	}
+	return nil
+}
+
+// readArchive reads all regular entries of the archive at path. Like ReadFile it tries again
+// up to READ_FILE_MAX_ATTEMPTS times if the archive cannot be opened yet, is empty or is
+// truncated, it is most likely still being written.
+func (h *Handler) readArchive(path string) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	var err error
+	for attempt := 1; attempt <= READ_FILE_MAX_ATTEMPTS; attempt++ {
+		if strings.HasSuffix(strings.ToLower(path), ".zip") {
+			entries, err = h.readZip(path)
+		} else {
+			entries, err = h.readTarGz(path)
+		}
+		if err == nil || !isIncompleteArchive(err) {
+			return entries, err
+		}
+		if attempt < READ_FILE_MAX_ATTEMPTS {
+			slog.Warn("Archive looks incomplete, retrying", "file", path, "attempt", attempt, "err", err)
+			time.Sleep(READ_FILE_RETRY_DELAY)
+		}
+	}
+	return nil, err
+}
+
+// isIncompleteArchive reports whether err could be caused by an archive that is still being
+// written: one that cannot be opened yet, or whose content ends early.
+func isIncompleteArchive(err error) bool {
+	return isTransientReadError(err) ||
+		errors.Is(err, zip.ErrFormat) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (h *Handler) readZip(path string) ([]archiveEntry, error) {
+	f, err := openChecked(path, h.parseOptions.Security)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat zip archive: %w", err)
+	}
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+
+	budget := h.maxArchiveSize
+	entries := make([]archiveEntry, 0, len(r.File))
+	for _, f := range r.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive entry %s: %w", f.Name, err)
+		}
+		content, err := readBounded(rc, &budget)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive entry %s: %w", f.Name, err)
+		}
+		entries = append(entries, archiveEntry{name: f.Name, content: content})
+	}
+	return entries, nil
+}
+
+func (h *Handler) readTarGz(path string) ([]archiveEntry, error) {
+	f, err := openChecked(path, h.parseOptions.Security)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	budget := h.maxArchiveSize
+	entries := make([]archiveEntry, 0)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := readBounded(tr, &budget)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive entry %s: %w", hdr.Name, err)
+		}
+		entries = append(entries, archiveEntry{name: hdr.Name, content: content})
+	}
+}
+
+// readBounded reads r completely while charging the bytes against budget, so the sum of
+// all entries can never exceed the archive limit no matter what the headers claim.
+func readBounded(r io.Reader, budget *int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, *budget+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > *budget {
+		return nil, ErrArchiveTooLarge
+	}
+	*budget -= int64(len(content))
+	return content, nil
+}
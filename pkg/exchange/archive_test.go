@@ -0,0 +1,202 @@
+package exchange
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// dropFile writes content next to dir and renames it in, so the watcher sees a complete file.
+func dropFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	tmp := filepath.Join(filepath.Dir(dir), name+".tmp")
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProcessArchive(t *testing.T) {
+	valid := map[string]string{
+		"one.txt":        "topic one\n---\nfirst",
+		"nested/two.txt": "topic two\nkey: value\n---\nsecond",
+	}
+	withInvalid := map[string]string{
+		"one.txt":    "topic one\n---\nfirst",
+		"two.txt":    "topic two\n---\nsecond",
+		"broken.txt": "---\nno topic",
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		wantErr bool
+		want    int64
+	}{
+		{name: "zip", file: "batch.zip", content: buildZip(t, valid), want: 2},
+		{name: "tar.gz", file: "batch.tar.gz", content: buildTarGz(t, valid), want: 2},
+		{name: "zip with invalid entry", file: "batch.zip", content: buildZip(t, withInvalid), wantErr: true},
+		{name: "tgz with invalid entry", file: "batch.tgz", content: buildTarGz(t, withInvalid), wantErr: true},
+		{name: "corrupt zip", file: "batch.zip", content: []byte("not a zip"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t)
+			path := writeFile(t, h.InputDir, tt.file, string(tt.content))
+
+			err := h.processArchive(context.Background(), &Process{Filepath: path})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processArchive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if h.Processed() != tt.want {
+				t.Errorf("Processed() = %d, want %d", h.Processed(), tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessArchiveTooLarge(t *testing.T) {
+	h := newTestHandler(t, WithMaxArchiveSize(40))
+	path := writeFile(t, h.InputDir, "batch.zip", string(buildZip(t, map[string]string{
+		"one.txt": "topic one\n---\nfirst message",
+		"two.txt": "topic two\n---\nsecond message",
+	})))
+
+	err := h.processArchive(context.Background(), &Process{Filepath: path})
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("processArchive() error = %v, want %v", err, ErrArchiveTooLarge)
+	}
+}
+
+func TestArchiveMovesToErrorDirAsUnit(t *testing.T) {
+	h := newTestHandler(t)
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	dropFile(t, h.InputDir, "batch.zip", buildZip(t, map[string]string{
+		"one.txt":    "topic one\n---\nfirst",
+		"two.txt":    "topic two\n---\nsecond",
+		"broken.txt": "---\nno topic",
+	}))
+
+	waitFor(t, "archive to be moved to error dir", func() bool {
+		_, err := os.Stat(filepath.Join(h.ErrorDir, "batch.zip"))
+		return err == nil
+	})
+	if h.Processed() != 0 {
+		t.Errorf("Processed() = %d, want 0 for a failed archive", h.Processed())
+	}
+
+	entries, err := os.ReadDir(h.ErrorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"batch.zip"}) {
+		t.Errorf("error dir = %v, want only the archive", names)
+	}
+}
+
+func TestArchiveWrittenInPlace(t *testing.T) {
+	files := map[string]string{
+		"one.txt": "topic one\n---\nfirst",
+		"two.txt": "topic two\n---\nsecond",
+	}
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+	}{
+		{name: "zip", file: "batch.zip", content: buildZip(t, files)},
+		{name: "tar.gz", file: "batch.tar.gz", content: buildTarGz(t, files)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, WithStore(newFakeStore()))
+			if err := h.Start(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(h.InputDir, tt.file)
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			half := len(tt.content) / 2
+			if _, err := f.Write(tt.content[:half]); err != nil {
+				t.Fatal(err)
+			}
+			// Give the watcher time to pick up the truncated archive.
+			time.Sleep(READ_FILE_RETRY_DELAY + READ_FILE_RETRY_DELAY/2)
+			if _, err := f.Write(tt.content[half:]); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			waitFor(t, "archive to be stored", func() bool { return h.Processed() == 2 })
+			if _, err := os.Stat(filepath.Join(h.ErrorDir, tt.file)); !os.IsNotExist(err) {
+				t.Errorf("archive was moved to the error dir")
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package exchange
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CoalescedCountKey is the metadata key holding how many notifications were coalesced.
+const CoalescedCountKey = "occurrences"
+
+// Coalescer is a Store that folds bursts of identical notifications into one. The first
+// notification for a key opens a window; notifications with the same key arriving while it
+// is open only bump a counter. When the window closes a single notification is inserted into
+// the wrapped store with the count in CoalescedCountKey. Every insert returns ErrCoalesced,
+// so the handler does not deliver the notifications of a burst one by one; with
+// WithCoalesceDeliverer the aggregate is delivered once it is stored.
+//
+// If the wrapped store implements Validator, invalid notifications are rejected right away
+// instead of failing when the window closes. Notifications in open windows only live in
+// memory, and the handler has already removed their input files: call Flush before exiting
+// or they are lost. Inserts failing when a window closes are passed to the handler set with
+// WithCoalesceErrorHandler, and only logged without one.
+type Coalescer struct {
+	store   Store
+	window  time.Duration
+	key     func(Notification) string
+	onError func(Notification, error)
+	deliver Deliverer
+
+	mu      sync.Mutex
+	pending map[string]*coalesced
+}
+
+type coalesced struct {
+	notif Notification
+	count int
+	timer *time.Timer
+}
+
+type CoalesceOption func(*Coalescer)
+
+// WithCoalesceKey sets what makes two notifications identical. The default is the topic
+// together with the notification fingerprint.
+func WithCoalesceKey(key func(Notification) string) CoalesceOption {
+	return func(c *Coalescer) {
+		c.key = key
+	}
+}
+
+// WithCoalesceErrorHandler calls fn with every coalesced notification the wrapped store
+// failed to insert when its window closed.
+func WithCoalesceErrorHandler(fn func(notif Notification, err error)) CoalesceOption {
+	return func(c *Coalescer) {
+		c.onError = fn
+	}
+}
+
+// WithCoalesceDeliverer delivers every coalesced notification after it was stored, recording
+// the outcome if the wrapped store implements StatusMarker. Use the Deliverer given to the
+// handler with WithDeliverer.
+func WithCoalesceDeliverer(d Deliverer) CoalesceOption {
+	return func(c *Coalescer) {
+		c.deliver = d
+	}
+}
+
+func NewCoalescer(store Store, window time.Duration, opts ...CoalesceOption) *Coalescer {
+	c := &Coalescer{
+		store:  store,
+		window: window,
+		key: func(n Notification) string {
+			return n.Topic + "\x00" + n.Fingerprint()
+		},
+		pending: make(map[string]*coalesced),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ValidateNotification checks notif against the wrapped store, if it implements Validator.
+func (c *Coalescer) ValidateNotification(notif Notification) error {
+	validator, ok := c.store.(Validator)
+	if !ok {
+		return nil
+	}
+	return validator.ValidateNotification(withCount(notif, 1))
+}
+
+func (c *Coalescer) InsertNotification(ctx context.Context, notif Notification) (int, error) {
+	if err := c.ValidateNotification(notif); err != nil {
+		return 0, err
+	}
+	key := c.key(notif)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.pending[key]; ok {
+		p.count++
+		return 0, ErrCoalesced
+	}
+	c.pending[key] = &coalesced{
+		notif: notif,
+		count: 1,
+		timer: time.AfterFunc(c.window, func() { c.flush(key) }),
+	}
+	return 0, ErrCoalesced
+}
+
+// Flush closes every open window right away, e.g. before shutting down.
+func (c *Coalescer) Flush() {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.pending))
+	for key, p := range c.pending {
+		p.timer.Stop()
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		c.flush(key)
+	}
+}
+
+func (c *Coalescer) flush(key string) {
+	c.mu.Lock()
+	p, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	notif := withCount(p.notif, p.count)
+	id, err := c.store.InsertNotification(ctx, notif)
+	if err != nil {
+		slog.Error("Error inserting coalesced notification", "topic", notif.Topic, "count", p.count, "err", err)
+		if c.onError != nil {
+			c.onError(notif, err)
+		}
+		return
+	}
+	if c.deliver != nil {
+		notif.id = id
+		deliverStored(ctx, c.deliver, c.store, notif)
+	}
+}
+
+// withCount returns a copy of notif with count in CoalescedCountKey. The content hash of
+// the first file does not describe the aggregate, so it is dropped; otherwise every later
+// window with the same content would be rejected as a duplicate.
+func withCount(notif Notification, count int) Notification {
+	notif.ContentHash = ""
+	metadata := make(map[string]string, len(notif.Metadata)+1)
+	for k, v := range notif.Metadata {
+		metadata[k] = v
+	}
+	metadata[CoalescedCountKey] = strconv.Itoa(count)
+	notif.Metadata = metadata
+	return notif
+}
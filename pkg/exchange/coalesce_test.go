@@ -0,0 +1,143 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCoalescerWindow(t *testing.T) {
+	store := newFakeStore()
+	c := NewCoalescer(store, 50*time.Millisecond)
+
+	const n = 25
+	for i := 0; i < n; i++ {
+		if _, err := c.InsertNotification(context.Background(), Notification{Topic: "sensor", Message: "threshold exceeded"}); !errors.Is(err, ErrCoalesced) {
+			t.Fatal(err)
+		}
+	}
+	c.InsertNotification(context.Background(), Notification{Topic: "sensor", Message: "back to normal"})
+
+	got := map[string]string{}
+	for i := 0; i < 2; i++ {
+		select {
+		case notif := <-store.notify:
+			got[notif.Message] = notif.Metadata[CoalescedCountKey]
+		case <-time.After(time.Second):
+			t.Fatal("window never closed")
+		}
+	}
+	if got["threshold exceeded"] != "25" || got["back to normal"] != "1" {
+		t.Errorf("coalesced counts = %v", got)
+	}
+	if store.count() != 2 {
+		t.Errorf("store has %d rows, want 2", store.count())
+	}
+}
+
+func TestCoalescerKeyAndFlush(t *testing.T) {
+	store := newFakeStore()
+	byTopic := func(n Notification) string { return n.Topic }
+	c := NewCoalescer(store, time.Hour, WithCoalesceKey(byTopic))
+
+	c.InsertNotification(context.Background(), Notification{Topic: "a", Message: "one", Metadata: map[string]string{"k": "v"}, ContentHash: "hash"})
+	c.InsertNotification(context.Background(), Notification{Topic: "a", Message: "two"})
+	c.InsertNotification(context.Background(), Notification{Topic: "b", Message: "three"})
+	if store.count() != 0 {
+		t.Fatalf("inserted before the window closed")
+	}
+
+	c.Flush()
+	if store.count() != 2 {
+		t.Fatalf("store has %d rows after Flush, want 2", store.count())
+	}
+	for _, notif := range store.inserted {
+		if notif.Topic == "a" && (notif.Message != "one" || notif.Metadata[CoalescedCountKey] != "2" || notif.Metadata["k"] != "v" || notif.ContentHash != "") {
+			t.Errorf("coalesced notification = %+v", notif)
+		}
+	}
+}
+
+// validatingStore rejects notifications with more than maxMetadata metadata entries.
+type validatingStore struct {
+	*fakeStore
+	maxMetadata int
+}
+
+func (s *validatingStore) ValidateNotification(notif Notification) error {
+	if len(notif.Metadata) > s.maxMetadata {
+		return fmt.Errorf("%w: too much metadata", ErrInvalidNotification)
+	}
+	return nil
+}
+
+func TestCoalescerValidatesUpFront(t *testing.T) {
+	store := &validatingStore{fakeStore: newFakeStore(), maxMetadata: 1}
+	c := NewCoalescer(store, time.Hour)
+
+	// The count added when the window closes is part of what gets validated.
+	_, err := c.InsertNotification(context.Background(), Notification{Topic: "a", Message: "one", Metadata: map[string]string{"k": "v"}})
+	if !errors.Is(err, ErrInvalidNotification) {
+		t.Fatalf("InsertNotification() error = %v, want ErrInvalidNotification", err)
+	}
+	if _, err := c.InsertNotification(context.Background(), Notification{Topic: "a", Message: "two"}); !errors.Is(err, ErrCoalesced) {
+		t.Fatal(err)
+	}
+
+	c.Flush()
+	if store.count() != 1 || store.inserted[0].Message != "two" {
+		t.Errorf("inserted = %+v, want only the valid notification", store.inserted)
+	}
+}
+
+func TestCoalescerReportsFailedFlush(t *testing.T) {
+	store := newFakeStore()
+	store.err = errStoreDown
+	var failed []Notification
+	c := NewCoalescer(store, time.Hour, WithCoalesceErrorHandler(func(notif Notification, err error) {
+		if !errors.Is(err, errStoreDown) {
+			t.Errorf("error = %v, want %v", err, errStoreDown)
+		}
+		failed = append(failed, notif)
+	}))
+
+	for range 3 {
+		if _, err := c.InsertNotification(context.Background(), Notification{Topic: "a", Message: "one"}); !errors.Is(err, ErrCoalesced) {
+			t.Fatal(err)
+		}
+	}
+	c.Flush()
+	if len(failed) != 1 || failed[0].Metadata[CoalescedCountKey] != "3" {
+		t.Errorf("failed = %+v, want the coalesced notification", failed)
+	}
+}
+
+func TestCoalescedBurstIsDeliveredOnce(t *testing.T) {
+	store := newFakeStore()
+	deliverer := &fakeDeliverer{}
+	c := NewCoalescer(store, 200*time.Millisecond, WithCoalesceDeliverer(deliverer))
+	h := newTestHandler(t, WithStore(c), WithDeliverer(deliverer))
+
+	const n = 5
+	for i := range n {
+		h.process(writeFile(t, h.InputDir, fmt.Sprintf("burst-%d.txt", i), "sensor\n---\nthreshold exceeded\n"))
+	}
+	waitFor(t, "burst to be handled", func() bool { return h.InFlight() == 0 && h.Processed() == n })
+	waitFor(t, "coalesced notification to be stored", func() bool { return store.count() == 1 })
+	waitFor(t, "coalesced notification to be delivered", func() bool {
+		deliverer.mu.Lock()
+		defer deliverer.mu.Unlock()
+		return len(deliverer.delivered) > 0
+	})
+
+	deliverer.mu.Lock()
+	defer deliverer.mu.Unlock()
+	if len(deliverer.delivered) != 1 || deliverer.delivered[0] != 1 {
+		t.Errorf("delivered = %v, want the stored aggregate once", deliverer.delivered)
+	}
+	if sent, _ := store.statuses(); len(sent) != 1 {
+		t.Errorf("sent = %v, want one status mark", sent)
+	}
+}
@@ -0,0 +1,26 @@
+package exchange
+
+import "testing"
+
+func TestContentHash(t *testing.T) {
+	h := newTestHandler(t)
+	hash := func(name, content string) string {
+		t.Helper()
+		proc := &Process{Filepath: writeFile(t, h.InputDir, name, content)}
+		if err := proc.ReadFile(); err != nil {
+			t.Fatal(err)
+		}
+		return proc.Notif.ContentHash
+	}
+
+	a := hash("a.txt", "topic\n---\nfirst message\n")
+	if len(a) != 64 {
+		t.Errorf("hash = %q, want 64 hex characters", a)
+	}
+	if b := hash("b.txt", "topic\n---\nfirst message\n"); b != a {
+		t.Errorf("identical files hash to %s and %s", a, b)
+	}
+	if c := hash("c.txt", "topic\n---\nsecond message\n"); c == a {
+		t.Errorf("different messages share hash %s", c)
+	}
+}
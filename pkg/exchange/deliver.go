@@ -0,0 +1,70 @@
+package exchange
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Deliverer pushes a stored notification to its recipients.
+type Deliverer interface {
+	Deliver(ctx context.Context, notif Notification) error
+}
+
+// StatusMarker is implemented by stores that track delivery state. The handler uses it, if
+// the configured store provides it, to record the outcome of a delivery.
+type StatusMarker interface {
+	MarkNotificationSent(ctx context.Context, notificationID int) error
+	MarkNotificationError(ctx context.Context, notificationID int) error
+}
+
+// WithDeliverer delivers every notification right after it was stored. Requires WithStore,
+// the delivery outcome is recorded if the store implements StatusMarker. A failed delivery
+// does not fail the file, the notification is already stored and can be redelivered.
+// Notifications with a SendAt in the future are only stored, they stay pending until
+// whatever polls the store for due notifications picks them up.
+func WithDeliverer(d Deliverer) Option {
+	return func(h *Handler) {
+		h.deliverer = d
+	}
+}
+
+// ID is the store ID of the notification, zero until it was stored.
+func (n Notification) ID() int {
+	return n.id
+}
+
+func (h *Handler) deliver(ctx context.Context, notif *Notification) {
+	if h.deliverer == nil || h.store == nil || notif.coalesced {
+		return
+	}
+	deliverStored(ctx, h.deliverer, h.store, *notif)
+}
+
+// deliverStored delivers notif unless it is scheduled for later and records the outcome if
+// store implements StatusMarker.
+func deliverStored(ctx context.Context, d Deliverer, store Store, notif Notification) {
+	if notif.SendAt.After(time.Now()) {
+		slog.Debug("Deferring scheduled notification", "notification", notif.id, "send_at", notif.SendAt)
+		return
+	}
+
+	deliverErr := d.Deliver(ctx, notif)
+	if deliverErr != nil {
+		slog.Error("Error delivering notification", "notification", notif.id, "topic", notif.Topic, "err", deliverErr)
+	}
+
+	marker, ok := store.(StatusMarker)
+	if !ok || notif.id == 0 {
+		return
+	}
+	var err error
+	if deliverErr != nil {
+		err = marker.MarkNotificationError(ctx, notif.id)
+	} else {
+		err = marker.MarkNotificationSent(ctx, notif.id)
+	}
+	if err != nil {
+		slog.Error("Error recording delivery status", "notification", notif.id, "err", err)
+	}
+}
@@ -0,0 +1,115 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeDeliverer struct {
+	mu        sync.Mutex
+	delivered []int
+	err       error
+}
+
+func (d *fakeDeliverer) Deliver(ctx context.Context, notif Notification) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.delivered = append(d.delivered, notif.ID())
+	return d.err
+}
+
+func (s *fakeStore) statuses() (sent, errored []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.sent...), append([]int(nil), s.errored...)
+}
+
+func TestDeliveryMarksStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		deliverErr  error
+		wantSent    []int
+		wantErrored []int
+	}{
+		{name: "delivered", wantSent: []int{1}},
+		{name: "delivery failed", deliverErr: errors.New("endpoint gone"), wantErrored: []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			deliverer := &fakeDeliverer{err: tt.deliverErr}
+			h := newTestHandler(t, WithStore(store), WithDeliverer(deliverer))
+
+			path := writeFile(t, h.InputDir, "notif.txt", "topic\n---\nmessage\n")
+			h.process(path)
+			waitFor(t, "delivery status", func() bool {
+				sent, errored := store.statuses()
+				return len(sent)+len(errored) == 1
+			})
+
+			sent, errored := store.statuses()
+			if !reflect.DeepEqual(sent, tt.wantSent) || !reflect.DeepEqual(errored, tt.wantErrored) {
+				t.Errorf("sent = %v, errored = %v, want %v and %v", sent, errored, tt.wantSent, tt.wantErrored)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("stored file is still in the input dir")
+			}
+		})
+	}
+}
+
+func TestInsertFailureMovesFileToErrorDir(t *testing.T) {
+	store := newFakeStore()
+	store.err = errors.New("disk I/O error")
+	deliverer := &fakeDeliverer{}
+	h := newTestHandler(t, WithStore(store), WithDeliverer(deliverer))
+
+	h.process(writeFile(t, h.InputDir, "notif.txt", "topic\n---\nmessage\n"))
+	waitFor(t, "file in error dir", func() bool {
+		_, err := os.Stat(filepath.Join(h.ErrorDir, "notif.txt"))
+		return err == nil
+	})
+	waitFor(t, "processing to finish", func() bool { return h.InFlight() == 0 })
+
+	if len(deliverer.delivered) != 0 {
+		t.Errorf("delivered %v although the insert failed", deliverer.delivered)
+	}
+	if sent, errored := store.statuses(); len(sent)+len(errored) != 0 {
+		t.Errorf("status recorded although the insert failed: sent %v, errored %v", sent, errored)
+	}
+}
+
+func TestScheduledNotificationIsNotDeliveredInline(t *testing.T) {
+	store := newFakeStore()
+	deliverer := &fakeDeliverer{}
+	h := newTestHandler(t, WithStore(store), WithDeliverer(deliverer))
+
+	sendAt := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	path := writeFile(t, h.InputDir, "notif.txt", "topic\nsend_at: "+sendAt+"\n---\nmessage\n")
+	h.process(path)
+	waitFor(t, "notification stored", func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.inserted) == 1
+	})
+	waitFor(t, "processing to finish", func() bool { return h.InFlight() == 0 })
+
+	deliverer.mu.Lock()
+	delivered := deliverer.delivered
+	deliverer.mu.Unlock()
+	if len(delivered) != 0 {
+		t.Errorf("delivered %v before its send_at", delivered)
+	}
+	if sent, errored := store.statuses(); len(sent)+len(errored) != 0 {
+		t.Errorf("status recorded for a scheduled notification: sent %v, errored %v", sent, errored)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("stored file is still in the input dir")
+	}
+}
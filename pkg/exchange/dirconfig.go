@@ -0,0 +1,111 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DirConfigName is the optional per-directory config file. It is never processed as a
+// notification itself.
+const DirConfigName = ".cland.yaml"
+
+var ErrMissingRequiredMetadata = errors.New("notification lacks metadata required by the directory config")
+
+// DirConfig holds defaults and rules for the files of one watched directory. They are
+// applied after the handler wide enrichments, so they take precedence over global config.
+type DirConfig struct {
+	// TopicPrefix is prepended to every topic that does not start with it yet.
+	TopicPrefix string `yaml:"topic_prefix"`
+	// Metadata is merged into every notification, keys set in the file win.
+	Metadata map[string]string `yaml:"metadata"`
+	// Required lists metadata keys a file must provide, files without them fail.
+	Required []string `yaml:"required"`
+}
+
+// loadDirConfig (re)reads the config file of dir. A missing file removes the config, a
+// broken one is logged and leaves the previous config in place.
+func (h *Handler) loadDirConfig(dir string) {
+	dir = fileKey(dir)
+	content, err := os.ReadFile(filepath.Join(dir, DirConfigName))
+	if errors.Is(err, os.ErrNotExist) {
+		h.mu.Lock()
+		delete(h.dirConfigs, dir)
+		h.mu.Unlock()
+		return
+	}
+	if err != nil {
+		slog.Error("Error reading directory config", "dir", dir, "err", err)
+		return
+	}
+
+	var cfg DirConfig
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		slog.Error("Error parsing directory config, keeping previous one", "dir", dir, "err", err)
+		return
+	}
+
+	slog.Info("Loaded directory config", "dir", dir, "prefix", cfg.TopicPrefix, "required", cfg.Required)
+	h.mu.Lock()
+	h.dirConfigs[dir] = &cfg
+	h.mu.Unlock()
+}
+
+func (h *Handler) dirConfig(dir string) *DirConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dirConfigs[fileKey(dir)]
+}
+
+// applyDirConfig applies the config of dir, if any, to notif.
+func (h *Handler) applyDirConfig(notif *Notification, dir string) error {
+	cfg := h.dirConfig(dir)
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.TopicPrefix != "" && !strings.HasPrefix(notif.Topic, cfg.TopicPrefix) {
+		notif.Topic = cfg.TopicPrefix + notif.Topic
+	}
+	for key, value := range cfg.Metadata {
+		if _, ok := notif.Metadata[key]; !ok {
+			notif.Metadata[key] = value
+		}
+	}
+	for _, key := range cfg.Required {
+		if _, ok := notif.Metadata[key]; !ok {
+			return fmt.Errorf("%w: %s", ErrMissingRequiredMetadata, key)
+		}
+	}
+	return nil
+}
+
+// isControlFile reports whether name is one of the files cland itself keeps in a watched
+// directory.
+func isControlFile(name string) bool {
+	return name == BusyMarkerName || name == DirConfigName
+}
+
+// partialFileSuffixes mark files that are still being written. The handler ignores them,
+// producers write to such a name and rename the file once it is complete.
+var partialFileSuffixes = []string{".tmp", ".part", ".partial"}
+
+func isPartialFile(name string) bool {
+	for _, suffix := range partialFileSuffixes {
+		if strings.HasSuffix(strings.ToLower(name), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIgnoredFile reports whether the handler leaves name alone when it shows up in a
+// watched directory.
+func isIgnoredFile(name string) bool {
+	return isControlFile(name) || isPartialFile(name)
+}
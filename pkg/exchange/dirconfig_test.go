@@ -0,0 +1,82 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirConfigAppliesOnlyToItsDirectory(t *testing.T) {
+	configured := newTestHandler(t)
+	plain := newTestHandler(t)
+
+	writeFile(t, configured.InputDir, DirConfigName, `
+topic_prefix: ci/
+metadata:
+  source: jenkins
+  branch: main
+required:
+  - job
+`)
+	configured.loadDirConfig(configured.InputDir)
+	plain.loadDirConfig(plain.InputDir)
+
+	content := "builds\nbranch: feature\njob: nightly\n---\nbuild green\n"
+
+	proc := &Process{Filepath: writeFile(t, configured.InputDir, "a.txt", content)}
+	if err := configured.prepare(context.Background(), proc); err != nil {
+		t.Fatal(err)
+	}
+	if proc.Notif.Topic != "ci/builds" {
+		t.Errorf("topic = %q, want ci/builds", proc.Notif.Topic)
+	}
+	if proc.Notif.Metadata["source"] != "jenkins" || proc.Notif.Metadata["branch"] != "feature" {
+		t.Errorf("metadata = %v, want default source and file branch", proc.Notif.Metadata)
+	}
+
+	proc = &Process{Filepath: writeFile(t, plain.InputDir, "a.txt", content)}
+	if err := plain.prepare(context.Background(), proc); err != nil {
+		t.Fatal(err)
+	}
+	if proc.Notif.Topic != "builds" {
+		t.Errorf("topic of other directory = %q, want builds", proc.Notif.Topic)
+	}
+	if _, ok := proc.Notif.Metadata["source"]; ok {
+		t.Errorf("default leaked into other directory: %v", proc.Notif.Metadata)
+	}
+
+	proc = &Process{Filepath: writeFile(t, configured.InputDir, "b.txt", "builds\n---\nno job\n")}
+	if err := configured.prepare(context.Background(), proc); !errors.Is(err, ErrMissingRequiredMetadata) {
+		t.Errorf("prepare() error = %v, want ErrMissingRequiredMetadata", err)
+	}
+}
+
+func TestDirConfigReload(t *testing.T) {
+	h := newTestHandler(t)
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	hasPrefix := func(prefix string) func() bool {
+		return func() bool {
+			cfg := h.dirConfig(h.InputDir)
+			return cfg != nil && cfg.TopicPrefix == prefix
+		}
+	}
+
+	writeFile(t, h.InputDir, DirConfigName, "topic_prefix: one/\n")
+	waitFor(t, "config to load", hasPrefix("one/"))
+	writeFile(t, h.InputDir, DirConfigName, "topic_prefix: two/\n")
+	waitFor(t, "config to reload", hasPrefix("two/"))
+
+	if err := os.Remove(filepath.Join(h.InputDir, DirConfigName)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "config to be dropped", func() bool { return h.dirConfig(h.InputDir) == nil })
+
+	if _, err := os.Stat(filepath.Join(h.ErrorDir, DirConfigName)); err == nil {
+		t.Errorf("config file was processed as a notification")
+	}
+}
@@ -1,6 +1,36 @@
 package exchange
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidPath  = errors.New("path must name a file directly inside the input or error directory")
+	ErrFileNotFound = errors.New("file not found in the input or error directory")
+	ErrFileBusy     = errors.New("file is already being processed")
+
+	ErrReservedMetadataKey = errors.New("metadata uses a reserved key")
+
+	ErrNotRunning = errors.New("handler is not running")
+
+	ErrProcessCapReached = errors.New("live process cap reached")
+
+	// ErrDuplicateNotification is returned by stores that already hold a notification with
+	// the same ContentHash or IdempotencyKey. The handler treats it as success without
+	// storing anything.
+	ErrDuplicateNotification = errors.New("notification is a duplicate")
+
+	// ErrCoalesced is returned by a Coalescer for notifications it folded into an open
+	// window. The handler treats it as success but does not deliver the notification, the
+	// Coalescer delivers the aggregate once it is stored.
+	ErrCoalesced = errors.New("notification was coalesced")
+
+	// ErrInvalidNotification is wrapped by store errors for notifications the store will
+	// never accept, e.g. because they exceed a size limit. Such errors are not retried.
+	ErrInvalidNotification = errors.New("invalid notification")
+)
 
 type NoTopicError struct {
 	File string
@@ -17,3 +47,85 @@ type EmptyMessageError struct {
 func (e *EmptyMessageError) Error() string {
 	return fmt.Sprintf("file %s has an empty message", e.File)
 }
+
+type AmbiguousTopicError struct {
+	File       string
+	Candidates []string
+}
+
+func (e *AmbiguousTopicError) Error() string {
+	return fmt.Sprintf("file %s has ambiguous topic lines: %s", e.File, strings.Join(e.Candidates, ", "))
+}
+
+type InvalidJSONError struct {
+	File string
+	Err  error
+}
+
+func (e *InvalidJSONError) Error() string {
+	return fmt.Sprintf("file %s is not valid JSON: %v", e.File, e.Err)
+}
+
+func (e *InvalidJSONError) Unwrap() error {
+	return e.Err
+}
+
+type SignatureError struct {
+	File   string
+	Device string
+	Err    error
+}
+
+func (e *SignatureError) Error() string {
+	if e.Device == "" {
+		return fmt.Sprintf("file %s failed signature verification: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("file %s failed signature verification for device %s: %v", e.File, e.Device, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+type InvalidTimestampError struct {
+	File  string
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *InvalidTimestampError) Error() string {
+	return fmt.Sprintf("file %s has an invalid %s timestamp %q: %v", e.File, e.Key, e.Value, e.Err)
+}
+
+func (e *InvalidTimestampError) Unwrap() error {
+	return e.Err
+}
+
+type FileTooLargeError struct {
+	File  string
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file %s exceeds the maximum size of %d bytes", e.File, e.Limit)
+}
+
+func setErrorFile(err error, file string) {
+	switch e := err.(type) {
+	case *NoTopicError:
+		e.File = file
+	case *EmptyMessageError:
+		e.File = file
+	case *AmbiguousTopicError:
+		e.File = file
+	case *InvalidJSONError:
+		e.File = file
+	case *SignatureError:
+		e.File = file
+	case *InvalidTimestampError:
+		e.File = file
+	case *FileTooLargeError:
+		e.File = file
+	}
+}
@@ -0,0 +1,91 @@
+package exchange
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// EventOutcome is what happened to a file at one step of processing.
+type EventOutcome int
+
+const (
+	// EventParsed means the file was read and parsed and is about to be stored.
+	EventParsed EventOutcome = iota
+	// EventStored means the notification was stored, or accepted if the handler has no
+	// store, and the file was consumed. It is terminal.
+	EventStored
+	// EventDuplicate means the store already held the notification and the file was
+	// consumed without storing it again. It is terminal.
+	EventDuplicate
+	// EventParseError means the file was refused or could not be read, verified or parsed.
+	EventParseError
+	// EventStoreError means the store failed to insert the notification.
+	EventStoreError
+	// EventMovedToError means the file was moved to the error directory. It is terminal.
+	EventMovedToError
+	// EventRetrying means a step failed with a transient error and is tried again, see
+	// WithMaxRetries.
+	EventRetrying
+)
+
+func (o EventOutcome) String() string {
+	switch o {
+	case EventParsed:
+		return "parsed"
+	case EventStored:
+		return "stored"
+	case EventDuplicate:
+		return "duplicate"
+	case EventParseError:
+		return "parse error"
+	case EventStoreError:
+		return "store error"
+	case EventMovedToError:
+		return "moved to error"
+	case EventRetrying:
+		return "retrying"
+	default:
+		return "unknown"
+	}
+}
+
+// Event reports a step in the processing of one input file. Every file the handler picks
+// up ends with exactly one terminal event.
+type Event struct {
+	Path    string
+	Outcome EventOutcome
+	// Err is the error that caused the outcome, if any. For EventMovedToError it also
+	// includes the error of the move itself if that failed.
+	Err error
+	// Attempt counts the failed attempts so far for EventRetrying.
+	Attempt int
+}
+
+// Terminal reports whether e is the last event for its file.
+func (e Event) Terminal() bool {
+	return e.Outcome == EventStored || e.Outcome == EventDuplicate || e.Outcome == EventMovedToError
+}
+
+// WithEventHandler calls fn for every processing event. fn runs synchronously on the
+// goroutine processing the file and must not block.
+func WithEventHandler(fn func(Event)) Option {
+	return func(h *Handler) {
+		h.onEvent = fn
+	}
+}
+
+func (h *Handler) emit(path string, outcome EventOutcome, err error) {
+	if h.onEvent != nil {
+		h.onEvent(Event{Path: path, Outcome: outcome, Err: err})
+	}
+}
+
+// moveToError moves the file of p to the error directory because of cause and emits the
+// terminal EventMovedToError.
+func (h *Handler) moveToError(p *Process, cause error) {
+	if err := h.errorFile(p); err != nil {
+		slog.Error("Error moving file to error dir", "err", err)
+		cause = errors.Join(cause, err)
+	}
+	h.emit(p.Filepath, EventMovedToError, cause)
+}
@@ -0,0 +1,147 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) record(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// outcomes returns the outcomes of path once its terminal event arrived.
+func (r *eventRecorder) outcomes(path string) ([]EventOutcome, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var outcomes []EventOutcome
+	terminal := false
+	for _, e := range r.events {
+		if e.Path == path {
+			outcomes = append(outcomes, e.Outcome)
+			terminal = terminal || e.Terminal()
+		}
+	}
+	return outcomes, terminal
+}
+
+func TestEvents(t *testing.T) {
+	errStore := errors.New("store down")
+	tests := []struct {
+		name     string
+		content  string
+		storeErr error
+		want     []EventOutcome
+	}{
+		{"stored", "topic\n---\nmessage\n", nil, []EventOutcome{EventParsed, EventStored}},
+		{"malformed", "---\nno topic\n", nil, []EventOutcome{EventParseError, EventMovedToError}},
+		{"store failure", "topic\n---\nmessage\n", errStore, []EventOutcome{EventParsed, EventStoreError, EventMovedToError}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.err = tt.storeErr
+			events := &eventRecorder{}
+			h := newTestHandler(t, WithStore(store), WithEventHandler(events.record))
+			if err := h.Start(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			path := writeFile(t, h.InputDir, "note.txt", tt.content)
+			waitFor(t, "terminal event", func() bool {
+				_, done := events.outcomes(path)
+				return done
+			})
+			waitFor(t, "file to be finished", func() bool { return h.InFlight() == 0 })
+
+			got, _ := events.outcomes(path)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("outcomes = %v, want %v", got, tt.want)
+			}
+			if tt.storeErr != nil {
+				events.mu.Lock()
+				last := events.events[len(events.events)-1]
+				events.mu.Unlock()
+				if !errors.Is(last.Err, tt.storeErr) {
+					t.Errorf("terminal event error = %v, want %v", last.Err, tt.storeErr)
+				}
+			}
+		})
+	}
+}
+
+func TestDuplicateEvent(t *testing.T) {
+	store := newFakeStore()
+	store.err = ErrDuplicateNotification
+	events := &eventRecorder{}
+	h := newTestHandler(t, WithStore(store), WithEventHandler(events.record))
+
+	path := writeFile(t, h.InputDir, "dup.txt", "topic\n---\nmessage\n")
+	h.process(path)
+	waitFor(t, "file to be finished", func() bool { return h.InFlight() == 0 })
+
+	got, _ := events.outcomes(path)
+	want := []EventOutcome{EventParsed, EventDuplicate}
+	if !slices.Equal(got, want) {
+		t.Errorf("outcomes = %v, want %v", got, want)
+	}
+}
+
+func TestReprocessEvents(t *testing.T) {
+	errStore := errors.New("store down")
+	tests := []struct {
+		name      string
+		content   string
+		inErrDir  bool
+		noStore   bool
+		storeErr  error
+		want      []EventOutcome
+		wantInErr bool
+	}{
+		{name: "stored", content: "topic\n---\nmessage\n", want: []EventOutcome{EventParsed, EventStored}},
+		{name: "without store", content: "topic\n---\nmessage\n", noStore: true, want: []EventOutcome{EventParsed, EventStored}},
+		{name: "duplicate", content: "topic\n---\nmessage\n", storeErr: ErrDuplicateNotification, want: []EventOutcome{EventParsed, EventDuplicate}},
+		{name: "malformed", content: "---\nno topic\n", want: []EventOutcome{EventParseError, EventMovedToError}, wantInErr: true},
+		{name: "malformed in error dir", content: "---\nno topic\n", inErrDir: true, want: []EventOutcome{EventParseError, EventMovedToError}, wantInErr: true},
+		{name: "store failure", content: "topic\n---\nmessage\n", storeErr: errStore, want: []EventOutcome{EventParsed, EventStoreError, EventMovedToError}, wantInErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := &eventRecorder{}
+			opts := []Option{WithEventHandler(events.record)}
+			if !tt.noStore {
+				store := newFakeStore()
+				store.err = tt.storeErr
+				opts = append(opts, WithStore(store))
+			}
+			h := newTestHandler(t, opts...)
+
+			dir := h.InputDir
+			if tt.inErrDir {
+				dir = h.ErrorDir
+			}
+			path := writeFile(t, dir, "note.txt", tt.content)
+			h.Reprocess(context.Background(), "note.txt")
+
+			got, terminal := events.outcomes(path)
+			if !slices.Equal(got, tt.want) || !terminal {
+				t.Errorf("outcomes = %v, want %v", got, tt.want)
+			}
+			_, err := os.Stat(filepath.Join(h.ErrorDir, "note.txt"))
+			if inErr := err == nil; inErr != tt.wantInErr {
+				t.Errorf("file in error dir = %v, want %v", inErr, tt.wantInErr)
+			}
+		})
+	}
+}
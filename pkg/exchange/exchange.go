@@ -1,8 +1,34 @@
 package exchange
 
+import (
+	"context"
+	"time"
+)
+
 type Notification struct {
-	id       int
-	Topic    string
-	Metadata map[string]string
-	Message  string
+	id        int
+	coalesced bool
+	Topic     string
+	Metadata  map[string]string
+	Message   string
+	Format    Format
+	// Priority orders delivery, higher first. It defaults to 0.
+	Priority int
+	// SendAt delays delivery until the given time, the zero value delivers right away.
+	SendAt time.Time
+	// ContentHash is the hex encoded sha256 of the file the notification was read from.
+	ContentHash string
+	// IdempotencyKey, if set, is unique among stored notifications. Inserting a second
+	// notification with the same key fails with ErrDuplicateNotification.
+	IdempotencyKey string
+}
+
+type Store interface {
+	InsertNotification(ctx context.Context, notif Notification) (int, error)
+}
+
+// Validator is implemented by stores that can tell whether they would accept a notification
+// without inserting it. Its errors should wrap ErrInvalidNotification.
+type Validator interface {
+	ValidateNotification(notif Notification) error
 }
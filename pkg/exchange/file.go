@@ -1,26 +1,138 @@
 package exchange
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+const BusyMarkerName = ".cland-busy"
+
 type Handler struct {
 	InputDir  string
 	ErrorDir  string
+	DoneDir   string
 	Running   bool
 	Processes *sync.Pool
+
+	busyHigh          int
+	busyLow           int
+	reconcileInterval time.Duration
+	errorRetention    time.Duration
+	filenamePattern   *regexp.Regexp
+	parseOptions      ParseOptions
+	metadataAllowlist map[string]bool
+	metadataExtraKey  string
+	maxArchiveSize    int64
+	store             Store
+	deliverer         Deliverer
+	parseStages       []ParseStage
+	recent            *recentBuffer
+	processSlots      chan struct{}
+	processCapPolicy  ProcessCapPolicy
+	workSlots         chan struct{}
+	signingKeys       SigningKeyStore
+	recursive         bool
+	onEvent           func(Event)
+	maxRetries        int
+	heartbeat         *heartbeat
+
+	largeMessageThreshold int
+	largeMessages         prometheus.Counter
+	metrics               prometheus.Registerer
+
+	// runMu guards Running and the lifecycle state below, which Start and Stop set up and tear down.
+	runMu   sync.Mutex
+	watcher *fsnotify.Watcher
+	stop    chan struct{}
+	loops   sync.WaitGroup
+	work    sync.WaitGroup
+	cancel  context.CancelFunc
+
+	mu         sync.Mutex
+	ctx        context.Context
+	inFlight   int
+	busy       bool
+	active     map[string]bool
+	dirConfigs map[string]*DirConfig
+	seen       map[string]time.Time
+	processed  atomic.Int64
+	moveMu     sync.Mutex
+	takeMu     sync.Mutex
+
+	// watchedDirs holds the directories a recursive handler watches.
+	watchedDirs map[string]bool
+}
+
+type Option func(*Handler)
+
+// WithBackpressure makes the handler write BusyMarkerName into the input directory once
+// high files are in flight and remove it again when the count drops to low. Cooperative
+// producers can check for the marker before dropping new files.
+func WithBackpressure(high, low int) Option {
+	return func(h *Handler) {
+		h.busyHigh = high
+		h.busyLow = low
+	}
+}
+
+// WithStrictTopic fails files whose head contains more than one candidate topic line
+// with an AmbiguousTopicError.
+func WithStrictTopic() Option {
+	return func(h *Handler) {
+		h.parseOptions.StrictTopic = true
+	}
+}
+
+// WithReservedKeyPolicy sets how reserved keys in the metadata section are treated.
+// The default promotes them to their typed field.
+func WithReservedKeyPolicy(policy ReservedKeyPolicy) Option {
+	return func(h *Handler) {
+		h.parseOptions.ReservedKeys = policy
+	}
+}
+
+// WithStore inserts every parsed notification into store. Files are deleted from the input
+// directory once their notification is stored, or moved to the done directory if one is set,
+// files whose insert fails are moved to the error directory. Without a store, handled files
+// stay where they are.
+func WithStore(store Store) Option {
+	return func(h *Handler) {
+		h.store = store
+	}
+}
+
+// WithMetrics registers the handler's Prometheus collectors with reg. NewHandler panics if
+// that fails, e.g. because another handler already registered them with reg.
+func WithMetrics(reg prometheus.Registerer) Option {
+	return func(h *Handler) {
+		h.metrics = reg
+	}
+}
+
+// WithDoneDir moves stored files into dir instead of deleting them.
+func WithDoneDir(dir string) Option {
+	return func(h *Handler) {
+		h.DoneDir = dir
+	}
 }
 
-func NewHandler(inputDir, errorDir string) *Handler {
+func NewHandler(inputDir, errorDir string, opts ...Option) *Handler {
 	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
 		slog.Info("Creating input directory", "dir", inputDir)
 		err = os.MkdirAll(inputDir, 0755)
@@ -35,7 +147,7 @@ func NewHandler(inputDir, errorDir string) *Handler {
 			panic(err)
 		}
 	}
-	return &Handler{
+	h := &Handler{
 		InputDir: inputDir,
 		ErrorDir: errorDir,
 		Running:  false,
@@ -44,83 +156,480 @@ func NewHandler(inputDir, errorDir string) *Handler {
 				return &Process{}
 			},
 		},
+		maxArchiveSize: DEFAULT_MAX_ARCHIVE_SIZE,
+		active:         make(map[string]bool),
+		dirConfigs:     make(map[string]*DirConfig),
+		watchedDirs:    make(map[string]bool),
+		ctx:            context.Background(),
+		seen:           make(map[string]time.Time),
+		largeMessages: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "cland",
+			Name:      "large_messages_total",
+			Help:      "Notifications whose message exceeded the large message warn threshold.",
+		}),
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.metrics != nil {
+		if err := h.metrics.Register(h.largeMessages); err != nil {
+			panic(fmt.Errorf("failed to register large message counter: %w", err))
+		}
+	}
+	if h.DoneDir != "" {
+		if err := os.MkdirAll(h.DoneDir, 0755); err != nil {
+			panic(err)
+		}
+	}
+	return h
 }
 
-func (h *Handler) Start() error {
+// Start watches the input directory until Stop is called or ctx is done, which stops the
+// handler as well. ctx also bounds the inserts of processed files. Starting a running
+// handler is a no-op, a stopped handler can be started again.
+func (h *Handler) Start(ctx context.Context) error {
+	h.runMu.Lock()
+	defer h.runMu.Unlock()
+	if h.Running {
+		return nil
+	}
+
 	slog.Info("Starting handler", "input", h.InputDir, "error", h.ErrorDir)
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		slog.Error("Error creating watcher", "err", err)
 		return err
 	}
+	h.loadDirConfig(h.InputDir)
+	h.mu.Lock()
+	clear(h.watchedDirs)
+	h.mu.Unlock()
+	if err := h.watchTree(watcher, h.InputDir); err != nil {
+		watcher.Close()
+		return err
+	}
 
+	h.mu.Lock()
+	h.ctx, h.cancel = context.WithCancel(ctx)
+	runCtx := h.ctx
+	h.mu.Unlock()
+	h.watcher = watcher
+	h.stop = make(chan struct{})
+	h.Running = true
+
+	go func(stop <-chan struct{}) {
+		select {
+		case <-ctx.Done():
+			slog.Info("Handler context done", "err", ctx.Err())
+			if err := h.Stop(context.Background()); err != nil && !errors.Is(err, ErrNotRunning) {
+				slog.Error("Error stopping handler", "err", err)
+			}
+		case <-stop:
+		}
+	}(h.stop)
+
+	h.loops.Add(2)
+	go h.watchLoop(watcher, h.stop)
+	// Files that were already there before the watcher was added never produce a Create
+	// event, so sweep them up once right away.
 	go func() {
-		defer watcher.Close()
-		for {
-			select {
-			case event := <-watcher.Events:
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					p := h.Processes.Get().(*Process)
-					p.Filepath = event.Name
-
-					go func(proc *Process) {
-						defer func() {
-							proc.Filepath = ""
-							proc.Notif = nil
-							h.Processes.Put(proc)
-						}()
-
-						slog.Info("New file created", "file", proc.Filepath)
-						err := proc.ReadFile()
-						if err != nil {
-							slog.Error("Error reading file", "err", err)
-							err = h.errorFile(proc)
-							if err != nil {
-								slog.Error("Error moving file to error dir", "err", err)
-							}
-							return
-						}
-
-						slog.Info("Notification parsed", "topic", proc.Notif.Topic, "metadata", proc.Notif.Metadata, "message", proc.Notif.Message)
-					}(p)
+		defer h.loops.Done()
+		h.reconcile()
+	}()
+	if h.reconcileInterval > 0 {
+		h.loops.Add(1)
+		go h.reconcileLoop(h.stop)
+	}
+	if h.errorRetention > 0 {
+		h.loops.Add(1)
+		go h.retentionLoop(h.stop)
+	}
+	if h.heartbeat != nil && h.store != nil {
+		h.loops.Add(1)
+		go h.heartbeatLoop(runCtx, h.stop, h.heartbeat.now())
+	}
+	return nil
+}
+
+func (h *Handler) watchLoop(watcher *fsnotify.Watcher, stop <-chan struct{}) {
+	defer h.loops.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Base(event.Name) == DirConfigName {
+				h.loadDirConfig(filepath.Dir(event.Name))
+				continue
+			}
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && h.isInputDir(event.Name) {
+				if !h.rewatchInputDir(watcher, stop) {
+					return
 				}
-			case werr := <-watcher.Errors:
-				slog.Error("Watcher error", "err", werr)
+				continue
+			}
+			if h.recursive && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && h.isWatchedDir(event.Name) {
+				h.unwatchDir(watcher, event.Name)
+				continue
 			}
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if isIgnoredFile(filepath.Base(event.Name)) {
+					continue
+				}
+				if h.recursive {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						h.watchNewDir(watcher, event.Name)
+						continue
+					}
+				}
+				h.process(event.Name)
+			}
+		case werr, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("Watcher error", "err", werr)
 		}
+	}
+}
+
+// Stop closes the watcher so no new files are picked up and waits for files already being
+// processed. If ctx ends first, running inserts are cancelled and ctx's error is returned.
+// Stopping a handler that is not running returns ErrNotRunning.
+func (h *Handler) Stop(ctx context.Context) error {
+	h.runMu.Lock()
+	defer h.runMu.Unlock()
+	if !h.Running {
+		return ErrNotRunning
+	}
+	h.Running = false
+	close(h.stop)
+	watcherErr := h.watcher.Close()
+	cancel := h.cancel
+
+	slog.Info("Stopping handler", "inFlight", h.InFlight())
+	// The loops are the only callers of process, once they are gone nothing adds to h.work.
+	h.loops.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		h.work.Wait()
+		close(done)
 	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return ctx.Err()
+	}
+
+	cancel()
+	slog.Info("Handler stopped")
+	return watcherErr
+}
 
-	return watcher.Add(h.InputDir)
+// context returns the context inserts run under, it is cancelled on shutdown.
+func (h *Handler) context() context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ctx
+}
+
+func (h *Handler) process(path string) {
+	key, ok := h.begin(path)
+	if !ok {
+		slog.Debug("File is already being processed", "file", path)
+		return
+	}
+	if !h.reserveProcess(path) {
+		h.finish(key, path)
+		return
+	}
+
+	p := h.Processes.Get().(*Process)
+	p.Filepath = path
+	p.Options = h.parseOptions
+	h.acquire()
+	ctx := h.context()
+	h.work.Add(1)
+
+	go func(proc *Process) {
+		defer h.work.Done()
+		defer func() {
+			h.finish(key, proc.Filepath)
+			proc.Filepath = ""
+			proc.Notif = nil
+			proc.Options = ParseOptions{}
+			h.Processes.Put(proc)
+			h.releaseProcess()
+			h.release()
+		}()
+		h.acquireWorkSlot()
+		defer h.releaseWorkSlot()
+
+		slog.Info("New file created", "file", proc.Filepath)
+		if isArchive(proc.Filepath) {
+			if err := h.processArchive(ctx, proc); err != nil {
+				slog.Error("Error processing archive", "err", err)
+				h.moveToError(proc, err)
+				return
+			}
+			h.emit(proc.Filepath, EventStored, nil)
+			return
+		}
+
+		err := h.retry(ctx, proc.Filepath, func() error { return h.prepare(ctx, proc) }, isTransientReadError)
+		if err != nil {
+			slog.Error("Error reading file", "err", err)
+			h.emit(proc.Filepath, EventParseError, err)
+			h.moveToError(proc, err)
+			return
+		}
+		h.emit(proc.Filepath, EventParsed, nil)
+
+		err = h.retry(ctx, proc.Filepath, func() error { return h.persist(ctx, proc.Notif) }, isTransientStoreError)
+		if errors.Is(err, ErrDuplicateNotification) {
+			slog.Info("Skipping duplicate notification", "file", proc.Filepath, "topic", proc.Notif.Topic)
+			h.consume(proc.Filepath)
+			h.emit(proc.Filepath, EventDuplicate, err)
+			return
+		}
+		if err != nil {
+			slog.Error("Error inserting notification", "file", proc.Filepath, "err", err)
+			h.emit(proc.Filepath, EventStoreError, err)
+			h.moveToError(proc, err)
+			return
+		}
+
+		h.processed.Add(1)
+		h.remember(proc.Notif)
+		slog.Info("Notification parsed", "topic", proc.Notif.Topic, "metadata", proc.Notif.Metadata, "message", proc.Notif.Message)
+		h.consume(proc.Filepath)
+		h.deliver(ctx, proc.Notif)
+		h.emit(proc.Filepath, EventStored, nil)
+	}(p)
+}
+
+// persist inserts notif into the store, if there is one.
+func (h *Handler) persist(ctx context.Context, notif *Notification) error {
+	if h.store == nil {
+		return nil
+	}
+	id, err := h.store.InsertNotification(ctx, *notif)
+	if errors.Is(err, ErrCoalesced) {
+		notif.coalesced = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	notif.id = id
+	return nil
+}
+
+// consume removes a fully handled file from the input directory, moving it to the done
+// directory if there is one. Files are only removed once their notifications are stored,
+// without a store they are left in place.
+func (h *Handler) consume(path string) {
+	if h.store == nil {
+		return
+	}
+	if h.DoneDir != "" {
+		if err := h.moveFile(path, h.DoneDir); err != nil && !os.IsNotExist(err) {
+			slog.Error("Error moving handled file to done dir", "file", path, "err", err)
+		}
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		slog.Error("Error removing handled file", "file", path, "err", err)
+	}
+}
+
+// prepare reads and parses the file of proc, verifies its signature, applies the configured
+// enrichments and runs the parse stages. Files the directory config rejects by their head
+// fail before the body is read.
+func (h *Handler) prepare(ctx context.Context, proc *Process) error {
+	if err := h.checkHead(proc.Filepath); err != nil {
+		return err
+	}
+	if err := proc.ReadFile(); err != nil {
+		return err
+	}
+	if err := h.verifySignature(ctx, proc.Notif); err != nil {
+		setErrorFile(err, proc.Filepath)
+		return err
+	}
+	h.enrich(proc)
+	if err := h.applyDirConfig(proc.Notif, filepath.Dir(proc.Filepath)); err != nil {
+		return err
+	}
+	return h.runStages(proc.Notif)
+}
+
+func (h *Handler) enrich(proc *Process) {
+	h.enrichFromFilename(proc.Notif, proc.Filepath)
+	h.applyMetadataAllowlist(proc.Notif, proc.Filepath)
+	h.checkMessageSize(proc.Notif, proc.Filepath)
+}
+
+func (h *Handler) acquire() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inFlight++
+	if h.busyHigh > 0 && !h.busy && h.inFlight >= h.busyHigh {
+		h.setBusy(true)
+	}
+}
+
+func (h *Handler) release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inFlight--
+	if h.busy && h.inFlight <= h.busyLow {
+		h.setBusy(false)
+	}
+}
+
+// setBusy must be called with h.mu held.
+func (h *Handler) setBusy(busy bool) {
+	marker := filepath.Join(h.InputDir, BusyMarkerName)
+	if busy {
+		slog.Warn("Handler is backpressured, writing busy marker", "inFlight", h.inFlight, "marker", marker)
+		if err := os.WriteFile(marker, nil, 0644); err != nil {
+			slog.Error("Error writing busy marker", "err", err)
+			return
+		}
+	} else {
+		slog.Info("Handler recovered, removing busy marker", "inFlight", h.inFlight, "marker", marker)
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			slog.Error("Error removing busy marker", "err", err)
+			return
+		}
+	}
+	h.busy = busy
+}
+
+func (h *Handler) Busy() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.busy
+}
+
+func (h *Handler) InFlight() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.inFlight
+}
+
+// Processed returns the number of files successfully handled since the handler was created.
+func (h *Handler) Processed() int64 {
+	return h.processed.Load()
 }
 
 func (h *Handler) errorFile(p *Process) error {
-	filename := filepath.Base(p.Filepath)
-	errorPath := filepath.Join(h.ErrorDir, filename)
+	return h.moveFile(p.Filepath, h.errorDirFor(p))
+}
 
-	if _, err := os.Stat(errorPath); err == nil {
-		timestamp := time.Now().Format("20060102150405")
-		errorPath = filepath.Join(h.ErrorDir, fmt.Sprintf("%s_%s", filename, timestamp))
+// moveFile moves path into dir. If a file with the same name is already there, a numeric
+// suffix is added before the extension, counting up until the name is free.
+func (h *Handler) moveFile(path, dir string) error {
+	// Moves are serialized so two files with the same name cannot pick the same free slot.
+	h.moveMu.Lock()
+	defer h.moveMu.Unlock()
+
+	name, ext := splitExt(filepath.Base(path))
+	target := filepath.Join(dir, name+ext)
+	for i := 1; ; i++ {
+		_, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check move target: %w", err)
+		}
+		target = filepath.Join(dir, fmt.Sprintf("%s_%d%s", name, i, ext))
 	}
 
-	return os.Rename(p.Filepath, errorPath)
+	return os.Rename(path, target)
+}
+
+// splitExt splits filename before its first dot, so multi-part extensions like .tar.gz stay
+// intact. A leading dot does not start an extension.
+func splitExt(filename string) (name, ext string) {
+	if filename == "" {
+		return "", ""
+	}
+	if i := strings.IndexByte(filename[1:], '.'); i >= 0 {
+		return filename[:i+1], filename[i+1:]
+	}
+	return filename, ""
 }
 
 type Process struct {
 	Filepath string
 	Notif    *Notification
+	Options  ParseOptions
+}
+
+type ParseOptions struct {
+	// StrictTopic rejects heads with more than one unindented line that is not a key: value
+	// pair, instead of silently using the first one as topic and treating the others as
+	// metadata continuations. Only indented lines continue a value in strict mode.
+	StrictTopic bool
+	// ReservedKeys decides what happens to reserved keys in the metadata section.
+	ReservedKeys ReservedKeyPolicy
+	// MaxFileSize fails larger files with a FileTooLargeError before they are loaded.
+	// Zero means DEFAULT_MAX_FILE_SIZE.
+	MaxFileSize int64
+	// Security, if set, is checked against every file as it is opened, see
+	// WithFileSecurityCheck.
+	Security *FileSecurityPolicy
 }
 
 const (
 	READ_FILE_MAX_ATTEMPTS = 5
 	READ_FILE_RETRY_DELAY  = 200 * time.Millisecond
+
+	DEFAULT_MAX_FILE_SIZE = 16 << 20
 )
 
+func (o ParseOptions) fileSizeLimit() int64 {
+	if o.MaxFileSize <= 0 {
+		return DEFAULT_MAX_FILE_SIZE
+	}
+	return o.MaxFileSize
+}
+
+// WithMaxFileSize sets the size in bytes above which input files are rejected unread.
+func WithMaxFileSize(size int64) Option {
+	return func(h *Handler) {
+		h.parseOptions.MaxFileSize = size
+	}
+}
+
+// ReadFile reads and parses the file of p. Empty files and files that look like they are
+// still being written, see looksComplete, are read again up to READ_FILE_MAX_ATTEMPTS
+// times. Producers that write large files should write them under a partial name and
+// rename them when done, see isPartialFile.
 func (p *Process) ReadFile() error {
+	limit := p.Options.fileSizeLimit()
+
 	var content []byte
 	var err error
 	for attempt := 1; attempt <= READ_FILE_MAX_ATTEMPTS; attempt++ {
-		content, err = os.ReadFile(p.Filepath)
+		content, err = readLimited(p.Filepath, limit, p.Options.Security)
+		var tooLarge *FileTooLargeError
+		if errors.As(err, &tooLarge) {
+			tooLarge.File = p.Filepath
+			return err
+		}
+		if errors.Is(err, ErrInsecureFile) {
+			return err
+		}
 		if err != nil {
 			slog.Warn("Failed to read file, retrying", "attempt", attempt, "err", err)
 			time.Sleep(READ_FILE_RETRY_DELAY)
@@ -131,6 +640,12 @@ func (p *Process) ReadFile() error {
 			time.Sleep(READ_FILE_RETRY_DELAY)
 			continue
 		}
+		// The last attempt parses whatever is there, so broken files still fail properly.
+		if attempt < READ_FILE_MAX_ATTEMPTS && !looksComplete(p.Filepath, content) {
+			slog.Warn("File looks incomplete, retrying", "file", p.Filepath, "attempt", attempt)
+			time.Sleep(READ_FILE_RETRY_DELAY)
+			continue
+		}
 		break
 	}
 	if err != nil {
@@ -140,22 +655,101 @@ func (p *Process) ReadFile() error {
 		return errors.New("file content is empty after retries")
 	}
 
-	lines := strings.Split(string(content), "\n")
-	notif, err := parse(lines)
+	return p.parseContent(content)
+}
+
+// looksComplete reports whether content can be a fully written file. Line format files
+// need a rule followed by message text, JSON files have to be valid JSON.
+func looksComplete(path string, content []byte) bool {
+	if isJSON(path, content) {
+		return json.Valid(content)
+	}
+	lines := splitLines(content)
+	for i, line := range lines {
+		if isRule(line) {
+			return strings.TrimSpace(strings.Join(lines[i+1:], "\n")) != ""
+		}
+	}
+	return false
+}
+
+// readLimited reads at most limit bytes of path and fails with a FileTooLargeError if
+// there is more. The file is opened through openChecked with policy.
+func readLimited(path string, limit int64, policy *FileSecurityPolicy) ([]byte, error) {
+	f, err := openChecked(path, policy)
 	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > limit {
+		return nil, &FileTooLargeError{Limit: limit}
+	}
+	content, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, &FileTooLargeError{Limit: limit}
+	}
+	return content, nil
+}
+
+func (p *Process) parseContent(content []byte) error {
+	var notif *Notification
+	var err error
+	if isJSON(p.Filepath, content) {
+		notif, err = parseJSON(content, p.Options)
+	} else {
+		notif, err = parseWithOptions(splitLines(content), p.Options)
+	}
+	if err != nil {
+		setErrorFile(err, p.Filepath)
 		return err
 	}
 
+	notif.Format = detectFormat(p.Filepath, notif.Metadata)
+	sum := sha256.Sum256(content)
+	notif.ContentHash = hex.EncodeToString(sum[:])
 	p.Notif = notif
 	return nil
 }
 
+// Parse reads a notification file from r, in the line format or as JSON, the same way the
+// handler does. Files without topic or message fail with a NoTopicError or
+// EmptyMessageError. Both LF and CRLF line endings are accepted.
+func Parse(r io.Reader) (*Notification, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification: %w", err)
+	}
+	return ParseBytes(content)
+}
+
+// ParseBytes is Parse for content already in memory.
+func ParseBytes(content []byte) (*Notification, error) {
+	if isJSON("", content) {
+		return parseJSON(content, ParseOptions{})
+	}
+	return parse(splitLines(content))
+}
+
+// splitLines splits content into lines without their line endings.
+func splitLines(content []byte) []string {
+	return strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+}
+
 func parse(lines []string) (*Notification, error) {
+	return parseWithOptions(lines, ParseOptions{})
+}
+
+func parseWithOptions(lines []string, opts ParseOptions) (*Notification, error) {
 	head := make([]string, 0)
 	message := make([]string, 0)
 	insideHead := true
 	for _, line := range lines {
-		if isRule(line) {
+		// Only the first rule separates head and message, later ones are message text.
+		if insideHead && isRule(line) {
 			insideHead = false
 			continue
 		}
@@ -172,15 +766,25 @@ func parse(lines []string) (*Notification, error) {
 		return nil, &NoTopicError{}
 	}
 
+	if opts.StrictTopic {
+		if bare := bareLines(head[1:]); len(bare) > 0 {
+			return nil, &AmbiguousTopicError{Candidates: append([]string{head[0]}, bare...)}
+		}
+	}
+
 	if len(message) < 1 {
 		return nil, &EmptyMessageError{}
 	}
 
-	return &Notification{
+	notif := &Notification{
 		Topic:    head[0],
 		Metadata: parseMetadata(head[1:]),
 		Message:  strings.Join(message, "\n"),
-	}, nil
+	}
+	if err := applyReservedKeys(notif, opts.ReservedKeys); err != nil {
+		return nil, err
+	}
+	return notif, nil
 }
 
 func cleanHead(head []string) []string {
@@ -194,24 +798,66 @@ func cleanHead(head []string) []string {
 	return cleaned
 }
 
+// bareLines returns the head lines that could be mistaken for a topic, unindented lines
+// without a colon. Strict parsing rejects them anywhere in the head, so metadata values can
+// only be continued by indented lines there.
+func bareLines(lines []string) []string {
+	bare := make([]string, 0)
+	for _, line := range lines {
+		if isContinuation(line) && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			bare = append(bare, line)
+		}
+	}
+	return bare
+}
+
+// isRule reports whether line separates head and message, which takes three or more
+// dashes and nothing else.
 func isRule(line string) bool {
-	return strings.HasPrefix(line, "---")
+	line = strings.TrimRight(line, " \t\r")
+	return len(line) >= 3 && strings.Trim(line, "-") == ""
 }
 
+// isComment reports whether line is a full-line comment, a bare "--" or "--" followed by
+// a space. Topics like "--urgent" are not comments.
 func isComment(line string) bool {
-	return strings.HasPrefix(line, "--")
+	line = strings.TrimRight(line, " \t\r")
+	return line == "--" || strings.HasPrefix(line, "-- ")
 }
 
+// parseMetadata reads "key: value" lines. A line that is indented or has no colon continues
+// the value of the key before it, trimmed and joined with a newline, so "details:" followed
+// by indented lines collects just those lines. Continuations before the first key, empty
+// lines and comments are skipped. A key without any value is kept with an empty one.
 func parseMetadata(lines []string) map[string]string {
 	metadata := make(map[string]string)
+	key := ""
 	for _, line := range lines {
+		if strings.TrimSpace(line) == "" || isComment(line) {
+			continue
+		}
+		if isContinuation(line) {
+			if key == "" {
+				continue
+			}
+			value := strings.TrimSpace(line)
+			if metadata[key] != "" {
+				value = metadata[key] + "\n" + value
+			}
+			metadata[key] = value
+			continue
+		}
+
 		parts := strings.SplitN(line, ":", 2)
-		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		key = strings.TrimSpace(parts[0])
+		if key == "" {
 			continue
 		}
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		metadata[key] = value
+		metadata[key] = strings.TrimSpace(parts[1])
 	}
 	return metadata
 }
+
+func isContinuation(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || !strings.Contains(line, ":")
+}
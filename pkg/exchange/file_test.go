@@ -1,72 +1,145 @@
 package exchange
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestParse(t *testing.T) {
-	type args struct {
-		lines []string
-	}
-	tests := []struct {
-		name string
-		args args
-		want *Notification
-	}{
-		{
-			name: "regular",
-			args: args{
-				lines: []string{
-					"topic",
-					"key1: value1",
-					"---",
-					"message",
-				},
+type parseArgs struct {
+	lines []string
+}
+
+// parseTests drive both the internal parse and the exported ParseBytes.
+var parseTests = []struct {
+	name string
+	args parseArgs
+	want *Notification
+}{
+	{
+		name: "regular",
+		args: parseArgs{
+			lines: []string{
+				"topic",
+				"key1: value1",
+				"---",
+				"message",
 			},
-			want: &Notification{
-				Topic: "topic",
-				Metadata: map[string]string{
-					"key1": "value1",
-				},
-				Message: "message",
+		},
+		want: &Notification{
+			Topic: "topic",
+			Metadata: map[string]string{
+				"key1": "value1",
 			},
+			Message: "message",
 		},
-		{
-			name: "empty metadata",
-			args: args{
-				lines: []string{
-					"topic",
-					"---",
-					"message",
-				},
+	},
+	{
+		name: "empty metadata",
+		args: parseArgs{
+			lines: []string{
+				"topic",
+				"---",
+				"message",
 			},
-			want: &Notification{
-				Topic:    "topic",
-				Metadata: map[string]string{},
-				Message:  "message",
+		},
+		want: &Notification{
+			Topic:    "topic",
+			Metadata: map[string]string{},
+			Message:  "message",
+		},
+	},
+	{
+		name: "complex metadata",
+		args: parseArgs{
+			lines: []string{
+				"topic",
+				"data: {\"key\": \"value\"}",
+				"---",
+				"message",
 			},
 		},
-		{
-			name: "complex metadata",
-			args: args{
-				lines: []string{
-					"topic",
-					"data: {\"key\": \"value\"}",
-					"---",
-					"message",
-				},
+		want: &Notification{
+			Topic: "topic",
+			Metadata: map[string]string{
+				"data": "{\"key\": \"value\"}",
 			},
-			want: &Notification{
-				Topic: "topic",
-				Metadata: map[string]string{
-					"data": "{\"key\": \"value\"}",
-				},
-				Message: "message",
+			Message: "message",
+		},
+	},
+	{
+		name: "dash prefixed topic",
+		args: parseArgs{
+			lines: []string{
+				"-- comment",
+				"--urgent",
+				"---",
+				"message",
 			},
 		},
-	}
-	for _, tt := range tests {
+		want: &Notification{
+			Topic:    "--urgent",
+			Metadata: map[string]string{},
+			Message:  "message",
+		},
+	},
+	{
+		name: "metadata value with dashes",
+		args: parseArgs{
+			lines: []string{
+				"topic",
+				"args: --force --dry-run",
+				"--",
+				"---",
+				"message",
+			},
+		},
+		want: &Notification{
+			Topic: "topic",
+			Metadata: map[string]string{
+				"args": "--force --dry-run",
+			},
+			Message: "message",
+		},
+	},
+	{
+		name: "rules inside the message",
+		args: parseArgs{
+			lines: []string{
+				"topic",
+				"---",
+				"# Release notes",
+				"---",
+				"--- end ---",
+			},
+		},
+		want: &Notification{
+			Topic:    "topic",
+			Metadata: map[string]string{},
+			Message:  "# Release notes\n---\n--- end ---",
+		},
+	},
+	{
+		name: "longer rule",
+		args: parseArgs{
+			lines: []string{
+				"topic",
+				"----",
+				"---extra is message text",
+			},
+		},
+		want: &Notification{
+			Topic:    "topic",
+			Metadata: map[string]string{},
+			Message:  "---extra is message text",
+		},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range parseTests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, _ := parse(tt.args.lines)
 			if !reflect.DeepEqual(got, tt.want) {
@@ -76,6 +149,52 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseBytes(t *testing.T) {
+	for _, ending := range []string{"\n", "\r\n"} {
+		for _, tt := range parseTests {
+			t.Run(fmt.Sprintf("%s %q", tt.name, ending), func(t *testing.T) {
+				content := strings.Join(tt.args.lines, ending)
+				got, err := ParseBytes([]byte(content))
+				if err != nil {
+					t.Fatalf("ParseBytes() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ParseBytes() = %v, want %v", got, tt.want)
+				}
+
+				got, err = Parse(strings.NewReader(content))
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Parse() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		var noTopic *NoTopicError
+		if _, err := ParseBytes([]byte("---\r\nmessage\r\n")); !errors.As(err, &noTopic) {
+			t.Errorf("ParseBytes() error = %v, want NoTopicError", err)
+		}
+		var emptyMessage *EmptyMessageError
+		if _, err := Parse(strings.NewReader("topic\r\nkey: value\r\n---")); !errors.As(err, &emptyMessage) {
+			t.Errorf("Parse() error = %v, want EmptyMessageError", err)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		got, err := ParseBytes([]byte(`{"topic": "alerts", "message": "disk full"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Topic != "alerts" || got.Message != "disk full" {
+			t.Errorf("ParseBytes() = %+v", got)
+		}
+	})
+}
+
 func TestParseErrors(t *testing.T) {
 	type args struct {
 		lines []string
@@ -162,6 +281,47 @@ func TestParseMetadata(t *testing.T) {
 				"key2": "value2",
 			},
 		},
+		{
+			name: "two-line value",
+			args: args{
+				lines: []string{
+					"summary: disk usage",
+					"  above 90 percent",
+					"host: a",
+				},
+			},
+			want: map[string]string{
+				"summary": "disk usage\nabove 90 percent",
+				"host":    "a",
+			},
+		},
+		{
+			name: "only continuation content",
+			args: args{
+				lines: []string{
+					"details:",
+					"\tline one: with colon",
+					"line two",
+					"empty:",
+				},
+			},
+			want: map[string]string{
+				"details": "line one: with colon\nline two",
+				"empty":   "",
+			},
+		},
+		{
+			name: "continuation before any key",
+			args: args{
+				lines: []string{
+					"  stray",
+					"key1: value1",
+				},
+			},
+			want: map[string]string{
+				"key1": "value1",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run("good_"+tt.name, func(t *testing.T) {
@@ -171,3 +331,143 @@ func TestParseMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStrictTopic(t *testing.T) {
+	type args struct {
+		lines []string
+		opts  ParseOptions
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Notification
+		wantErr error
+	}{
+		{
+			// Since multi-line metadata values, the bare line continues key1 when lenient.
+			name: "ambiguous lenient",
+			args: args{
+				lines: []string{
+					"topic",
+					"key1: value1",
+					"other topic",
+					"---",
+					"message",
+				},
+			},
+			want: &Notification{
+				Topic: "topic",
+				Metadata: map[string]string{
+					"key1": "value1\nother topic",
+				},
+				Message: "message",
+			},
+		},
+		{
+			name: "ambiguous before metadata lenient",
+			args: args{
+				lines: []string{
+					"topic",
+					"other topic",
+					"key1: value1",
+					"---",
+					"message",
+				},
+			},
+			want: &Notification{
+				Topic: "topic",
+				Metadata: map[string]string{
+					"key1": "value1",
+				},
+				Message: "message",
+			},
+		},
+		{
+			name: "ambiguous strict",
+			args: args{
+				lines: []string{
+					"topic",
+					"key1: value1",
+					"other topic",
+					"---",
+					"message",
+				},
+				opts: ParseOptions{StrictTopic: true},
+			},
+			wantErr: &AmbiguousTopicError{},
+		},
+		{
+			name: "ambiguous before metadata strict",
+			args: args{
+				lines: []string{
+					"topic",
+					"other topic",
+					"key1: value1",
+					"---",
+					"message",
+				},
+				opts: ParseOptions{StrictTopic: true},
+			},
+			wantErr: &AmbiguousTopicError{},
+		},
+		{
+			name: "continuation strict",
+			args: args{
+				lines: []string{
+					"topic",
+					"details:",
+					"  more",
+					"\teven more",
+					"---",
+					"message",
+				},
+				opts: ParseOptions{StrictTopic: true},
+			},
+			want: &Notification{
+				Topic: "topic",
+				Metadata: map[string]string{
+					"details": "more\neven more",
+				},
+				Message: "message",
+			},
+		},
+		{
+			name: "unambiguous strict",
+			args: args{
+				lines: []string{
+					"topic",
+					"-- comment",
+					"key1: value1",
+					"",
+					"---",
+					"message",
+				},
+				opts: ParseOptions{StrictTopic: true},
+			},
+			want: &Notification{
+				Topic: "topic",
+				Metadata: map[string]string{
+					"key1": "value1",
+				},
+				Message: "message",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWithOptions(tt.args.lines, tt.args.opts)
+			if tt.wantErr != nil {
+				if reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+					t.Fatalf("parseWithOptions() error = %v, want %T", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseWithOptions() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWithOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
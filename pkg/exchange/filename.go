@@ -0,0 +1,40 @@
+package exchange
+
+import (
+	"log/slog"
+	"path/filepath"
+	"regexp"
+)
+
+// WithFilenamePattern matches the base name of every input file against pattern and copies
+// the named capture groups into the notification metadata. Keys set in the file itself win.
+func WithFilenamePattern(pattern *regexp.Regexp) Option {
+	return func(h *Handler) {
+		h.filenamePattern = pattern
+	}
+}
+
+func (h *Handler) enrichFromFilename(notif *Notification, path string) {
+	if h.filenamePattern == nil {
+		return
+	}
+
+	match := h.filenamePattern.FindStringSubmatch(filepath.Base(path))
+	if match == nil {
+		slog.Debug("Filename does not match pattern", "file", path, "pattern", h.filenamePattern)
+		return
+	}
+
+	if notif.Metadata == nil {
+		notif.Metadata = make(map[string]string)
+	}
+	for i, name := range h.filenamePattern.SubexpNames() {
+		if name == "" || match[i] == "" {
+			continue
+		}
+		if _, ok := notif.Metadata[name]; ok {
+			continue
+		}
+		notif.Metadata[name] = match[i]
+	}
+}
@@ -0,0 +1,66 @@
+package exchange
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestEnrichFromFilename(t *testing.T) {
+	pattern := regexp.MustCompile(`^(?P<timestamp>\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2})_(?P<service>[^_]+)_(?P<kind>[^.]+)\.txt$`)
+
+	tests := []struct {
+		name     string
+		path     string
+		metadata map[string]string
+		want     map[string]string
+	}{
+		{
+			name: "matching",
+			path: "input/2024-01-15T10-00-00_serviceA_alert.txt",
+			want: map[string]string{
+				"timestamp": "2024-01-15T10-00-00",
+				"service":   "serviceA",
+				"kind":      "alert",
+			},
+		},
+		{
+			name:     "file metadata wins",
+			path:     "input/2024-01-15T10-00-00_serviceA_alert.txt",
+			metadata: map[string]string{"service": "from-file", "other": "x"},
+			want: map[string]string{
+				"timestamp": "2024-01-15T10-00-00",
+				"service":   "from-file",
+				"kind":      "alert",
+				"other":     "x",
+			},
+		},
+		{
+			name:     "not matching",
+			path:     "input/notification.txt",
+			metadata: map[string]string{"other": "x"},
+			want:     map[string]string{"other": "x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{}
+			WithFilenamePattern(pattern)(h)
+
+			notif := &Notification{Topic: "topic", Metadata: tt.metadata, Message: "message"}
+			h.enrichFromFilename(notif, tt.path)
+			if !reflect.DeepEqual(notif.Metadata, tt.want) {
+				t.Errorf("metadata = %v, want %v", notif.Metadata, tt.want)
+			}
+		})
+	}
+
+	t.Run("no pattern", func(t *testing.T) {
+		h := &Handler{}
+		notif := &Notification{Topic: "topic", Message: "message"}
+		h.enrichFromFilename(notif, "input/2024-01-15T10-00-00_serviceA_alert.txt")
+		if notif.Metadata != nil {
+			t.Errorf("metadata = %v, want nil", notif.Metadata)
+		}
+	})
+}
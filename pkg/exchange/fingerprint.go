@@ -0,0 +1,32 @@
+package exchange
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+)
+
+// Fingerprint returns a stable hash of the topic, the message and the values of the given
+// metadata keys. Metadata not listed is considered volatile and ignored.
+func (n Notification) Fingerprint(metadataKeys ...string) string {
+	keys := append([]string(nil), metadataKeys...)
+	sort.Strings(keys)
+
+	h := sha256.New()
+	writeField := func(s string) {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+	writeField(n.Topic)
+	writeField(n.Message)
+	for _, key := range keys {
+		value, ok := n.Metadata[key]
+		if !ok {
+			continue
+		}
+		writeField(key)
+		writeField(value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
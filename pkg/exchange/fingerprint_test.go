@@ -0,0 +1,36 @@
+package exchange
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	base := Notification{
+		Topic:    "alerts",
+		Message:  "disk full",
+		Metadata: map[string]string{"host": "a", "date": "2024-01-01"},
+	}
+
+	same := base
+	same.Metadata = map[string]string{"host": "b", "date": "2024-02-02"}
+	if base.Fingerprint() != same.Fingerprint() {
+		t.Errorf("fingerprints differ although only volatile metadata changed")
+	}
+	if base.Fingerprint("host") == same.Fingerprint("host") {
+		t.Errorf("fingerprints match although included metadata key differs")
+	}
+	if base.Fingerprint("host", "date") != base.Fingerprint("date", "host") {
+		t.Errorf("fingerprint depends on metadata key order")
+	}
+
+	other := base
+	other.Message = "disk almost full"
+	if base.Fingerprint() == other.Fingerprint() {
+		t.Errorf("fingerprints match for different messages")
+	}
+
+	// Field boundaries must not be ambiguous.
+	a := Notification{Topic: "ab", Message: "c"}
+	b := Notification{Topic: "a", Message: "bc"}
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Errorf("fingerprints match for different topic/message splits")
+	}
+}
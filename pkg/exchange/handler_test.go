@@ -0,0 +1,481 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestHandler(t *testing.T, opts ...Option) *Handler {
+	t.Helper()
+	dir := t.TempDir()
+	h := NewHandler(filepath.Join(dir, "input"), filepath.Join(dir, "error"), opts...)
+	// A running handler recreates its input dir, it has to be stopped before the temp dir
+	// is removed.
+	t.Cleanup(func() { h.Stop(context.Background()) })
+	return h
+}
+
+func TestBackpressureMarker(t *testing.T) {
+	h := newTestHandler(t, WithBackpressure(3, 1))
+	marker := filepath.Join(h.InputDir, BusyMarkerName)
+
+	assertMarker := func(want bool) {
+		t.Helper()
+		_, err := os.Stat(marker)
+		if got := err == nil; got != want {
+			t.Fatalf("marker exists = %v, want %v (inFlight %d)", got, want, h.InFlight())
+		}
+		if h.Busy() != want {
+			t.Fatalf("Busy() = %v, want %v", h.Busy(), want)
+		}
+	}
+
+	h.acquire()
+	h.acquire()
+	assertMarker(false)
+
+	h.acquire()
+	assertMarker(true)
+
+	h.release()
+	assertMarker(true)
+
+	h.release()
+	assertMarker(false)
+
+	h.acquire()
+	assertMarker(false)
+
+	h.acquire()
+	assertMarker(true)
+}
+
+func TestBackpressureDisabled(t *testing.T) {
+	h := newTestHandler(t)
+
+	for i := 0; i < 100; i++ {
+		h.acquire()
+	}
+	if h.Busy() {
+		t.Fatalf("Busy() = true without backpressure configured")
+	}
+	if _, err := os.Stat(filepath.Join(h.InputDir, BusyMarkerName)); err == nil {
+		t.Fatalf("marker written without backpressure configured")
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReconcileSweep(t *testing.T) {
+	h := newTestHandler(t, WithReconcileInterval(20*time.Millisecond))
+
+	// Written before Start, so the watcher never announces them.
+	writeFile(t, h.InputDir, "missed.txt", "topic\n---\nmessage\n")
+	writeFile(t, h.InputDir, "broken.txt", "---\nmessage\n")
+
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "sweep to process missed file", func() bool { return h.Processed() == 1 })
+	waitFor(t, "sweep to move broken file", func() bool {
+		_, err := os.Stat(filepath.Join(h.ErrorDir, "broken.txt"))
+		return err == nil
+	})
+
+	// Already handled files must not be picked up again by later sweeps.
+	time.Sleep(100 * time.Millisecond)
+	if got := h.Processed(); got != 1 {
+		t.Errorf("Processed() = %d after several sweeps, want 1", got)
+	}
+}
+
+func TestErrorFileCollisions(t *testing.T) {
+	h := newTestHandler(t)
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		path := writeFile(t, h.InputDir, "same.txt", fmt.Sprintf("broken %d", i))
+		if err := h.errorFile(&Process{Filepath: path}); err != nil {
+			t.Fatalf("errorFile() #%d error = %v", i, err)
+		}
+	}
+
+	entries, err := os.ReadDir(h.ErrorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != n {
+		t.Fatalf("error dir has %d files, want %d", len(entries), n)
+	}
+
+	contents := make(map[string]bool, n)
+	for _, e := range entries {
+		content, err := os.ReadFile(filepath.Join(h.ErrorDir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[string(content)] = true
+	}
+	if len(contents) != n {
+		t.Errorf("only %d distinct files survived, want %d", len(contents), n)
+	}
+}
+
+func TestErrorFileSuffixes(t *testing.T) {
+	h := newTestHandler(t)
+	sub := filepath.Join(h.InputDir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two broken files with the same name fail back to back, well within one second.
+	h.process(writeFile(t, h.InputDir, "same.txt", "---\nfirst\n"))
+	h.process(writeFile(t, sub, "same.txt", "---\nsecond\n"))
+	waitFor(t, "both files to fail", func() bool { return h.InFlight() == 0 })
+
+	contents := make(map[string]string)
+	for _, name := range []string{"same.txt", "same_1.txt"} {
+		content, err := os.ReadFile(filepath.Join(h.ErrorDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[string(content)] = name
+	}
+	if len(contents) != 2 {
+		t.Errorf("error dir files = %v, want two distinct files", contents)
+	}
+
+	for _, tt := range []struct{ name, base, ext string }{
+		{"note.txt", "note", ".txt"},
+		{"logs.tar.gz", "logs", ".tar.gz"},
+		{".hidden", ".hidden", ""},
+		{"plain", "plain", ""},
+	} {
+		if base, ext := splitExt(tt.name); base != tt.base || ext != tt.ext {
+			t.Errorf("splitExt(%q) = %q, %q, want %q, %q", tt.name, base, ext, tt.base, tt.ext)
+		}
+	}
+}
+
+func TestLiveProcessCapReject(t *testing.T) {
+	h := newTestHandler(t, WithMaxLiveProcesses(2, ProcessCapReject))
+
+	// Occupy every slot as if two files were stuck downstream.
+	h.processSlots <- struct{}{}
+	h.processSlots <- struct{}{}
+
+	const burst = 5
+	for i := 0; i < burst; i++ {
+		h.process(writeFile(t, h.InputDir, fmt.Sprintf("burst%d.txt", i), "topic\n---\nmessage\n"))
+	}
+
+	entries, err := os.ReadDir(h.ErrorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != burst {
+		t.Errorf("error dir has %d files, want %d rejected", len(entries), burst)
+	}
+	if got := h.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want 0", got)
+	}
+
+	<-h.processSlots
+	h.process(writeFile(t, h.InputDir, "after.txt", "topic\n---\nmessage\n"))
+	waitFor(t, "file to be processed once a slot is free", func() bool { return h.Processed() == 1 })
+}
+
+func TestLiveProcessCapBlock(t *testing.T) {
+	h := newTestHandler(t, WithMaxLiveProcesses(1, ProcessCapBlock))
+	h.processSlots <- struct{}{}
+
+	done := make(chan struct{})
+	go func() {
+		h.process(writeFile(t, h.InputDir, "blocked.txt", "topic\n---\nmessage\n"))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("process returned although the cap was reached")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	<-h.processSlots
+	<-done
+	waitFor(t, "blocked file to be processed", func() bool { return h.Processed() == 1 })
+	waitFor(t, "slot to be released", func() bool { return len(h.processSlots) == 0 })
+}
+
+// concurrencyStore records the highest number of concurrent inserts.
+type concurrencyStore struct {
+	mu      sync.Mutex
+	current int
+	max     int
+	count   int
+}
+
+func (s *concurrencyStore) InsertNotification(ctx context.Context, notif Notification) (int, error) {
+	s.mu.Lock()
+	s.current++
+	s.max = max(s.max, s.current)
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current--
+	s.count++
+	return s.count, nil
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	store := &concurrencyStore{}
+	h := newTestHandler(t, WithStore(store), WithMaxConcurrency(2))
+
+	const files = 10
+	for i := 0; i < files; i++ {
+		h.process(writeFile(t, h.InputDir, fmt.Sprintf("file%d.txt", i), "topic\n---\nmessage\n"))
+	}
+	waitFor(t, "all files to be processed", func() bool { return h.Processed() == files })
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.max > 2 {
+		t.Errorf("%d files were processed concurrently, want at most 2", store.max)
+	}
+	if store.max < 2 {
+		t.Errorf("files were processed one at a time, want 2 concurrently")
+	}
+}
+
+type blockingStore struct {
+	started chan struct{}
+}
+
+func (s *blockingStore) InsertNotification(ctx context.Context, notif Notification) (int, error) {
+	close(s.started)
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestStopDrainsInFlightFiles(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store), WithReconcileInterval(time.Hour))
+
+	if err := h.Stop(context.Background()); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("Stop() before Start error = %v, want %v", err, ErrNotRunning)
+	}
+
+	for round := 0; round < 2; round++ {
+		if err := h.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, h.InputDir, fmt.Sprintf("round%d.txt", round), "topic\n---\nmessage\n")
+		<-store.notify
+
+		if err := h.Stop(context.Background()); err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+		if h.Running {
+			t.Errorf("Running = true after Stop")
+		}
+		if got := h.InFlight(); got != 0 {
+			t.Errorf("InFlight() = %d after Stop", got)
+		}
+		if got := h.Processed(); got != int64(round+1) {
+			t.Errorf("Processed() = %d after Stop, want %d", got, round+1)
+		}
+	}
+
+	// Files dropped while stopped are not picked up.
+	writeFile(t, h.InputDir, "stopped.txt", "topic\n---\nmessage\n")
+	time.Sleep(50 * time.Millisecond)
+	if got := store.count(); got != 2 {
+		t.Errorf("store has %d notifications, want 2", got)
+	}
+	if err := h.Stop(context.Background()); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("second Stop() error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func TestStopCancelsInsertsAfterDeadline(t *testing.T) {
+	store := &blockingStore{started: make(chan struct{})}
+	h := newTestHandler(t, WithStore(store))
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, h.InputDir, "slow.txt", "topic\n---\nmessage\n")
+	<-store.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := h.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := h.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d after Stop returned", got)
+	}
+	if _, err := os.Stat(filepath.Join(h.ErrorDir, "slow.txt")); err != nil {
+		t.Errorf("cancelled file not in error dir: %v", err)
+	}
+}
+
+func TestStartContextCancelStops(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := h.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, h.InputDir, "before.txt", "topic\n---\nmessage\n")
+	<-store.notify
+
+	cancel()
+	waitFor(t, "handler to stop", func() bool {
+		err := h.Stop(context.Background())
+		return errors.Is(err, ErrNotRunning)
+	})
+
+	writeFile(t, h.InputDir, "after.txt", "topic\n---\nmessage\n")
+	time.Sleep(50 * time.Millisecond)
+	if got := store.count(); got != 1 {
+		t.Errorf("store has %d notifications, want 1", got)
+	}
+}
+
+func TestStartupScan(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store))
+
+	writeFile(t, h.InputDir, "first.txt", "topic\n---\nfirst\n")
+	writeFile(t, h.InputDir, "second.txt", "topic\n---\nsecond\n")
+	writeFile(t, h.InputDir, "malformed.txt", "---\nno topic\n")
+
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop(context.Background())
+
+	waitFor(t, "existing files to be processed", func() bool { return h.Processed() == 2 })
+	waitFor(t, "malformed file in error dir", func() bool {
+		_, err := os.Stat(filepath.Join(h.ErrorDir, "malformed.txt"))
+		return err == nil
+	})
+	if got := store.count(); got != 2 {
+		t.Errorf("store has %d notifications, want 2", got)
+	}
+}
+
+func TestStartupScanProcessesExactlyOnce(t *testing.T) {
+	tests := []struct {
+		name  string
+		store *fakeStore
+	}{
+		{name: "files kept"},
+		{name: "files consumed", store: newFakeStore()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.store != nil {
+				opts = append(opts, WithStore(tt.store))
+			}
+			h := newTestHandler(t, opts...)
+			paths := []string{
+				writeFile(t, h.InputDir, "one.txt", "topic\n---\none\n"),
+				writeFile(t, h.InputDir, "two.txt", "topic\n---\ntwo\n"),
+			}
+
+			if err := h.Start(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer h.Stop(context.Background())
+			waitFor(t, "startup scan", func() bool { return h.Processed() == 2 && h.InFlight() == 0 })
+
+			// Create events racing the scan arrive after it handled the files.
+			for _, path := range paths {
+				h.process(path)
+			}
+			h.reconcile()
+			time.Sleep(50 * time.Millisecond)
+
+			if got := h.Processed(); got != 2 {
+				t.Errorf("Processed() = %d, want 2", got)
+			}
+			if tt.store != nil && tt.store.count() != 2 {
+				t.Errorf("store has %d notifications, want 2", tt.store.count())
+			}
+			if entries, _ := os.ReadDir(h.ErrorDir); len(entries) != 0 {
+				t.Errorf("error dir has %d files, want none", len(entries))
+			}
+		})
+	}
+}
+
+func TestConsumeHandledFiles(t *testing.T) {
+	t.Run("deleted by default", func(t *testing.T) {
+		h := newTestHandler(t, WithStore(newFakeStore()))
+		path := writeFile(t, h.InputDir, "note.txt", "topic\n---\nbody\n")
+
+		h.process(path)
+		waitFor(t, "file to be processed", func() bool { return h.Processed() == 1 && h.InFlight() == 0 })
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("input file still exists: %v", err)
+		}
+	})
+
+	t.Run("moved to done dir", func(t *testing.T) {
+		doneDir := filepath.Join(t.TempDir(), "done")
+		h := newTestHandler(t, WithStore(newFakeStore()), WithDoneDir(doneDir))
+		sub := filepath.Join(h.InputDir, "sub")
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		// Same basename from two directories must not clobber each other in the done dir.
+		h.process(writeFile(t, h.InputDir, "note.txt", "topic\n---\nfirst\n"))
+		waitFor(t, "first file", func() bool { return h.Processed() == 1 && h.InFlight() == 0 })
+		h.process(writeFile(t, sub, "note.txt", "topic\n---\nsecond\n"))
+		waitFor(t, "second file", func() bool { return h.Processed() == 2 && h.InFlight() == 0 })
+
+		entries, err := os.ReadDir(doneDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Fatalf("done dir has %d files, want 2", len(entries))
+		}
+		if _, err := os.Stat(filepath.Join(h.InputDir, "note.txt")); !os.IsNotExist(err) {
+			t.Errorf("input file still exists: %v", err)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package exchange
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ReadHead reads path only up to and including the first rule line and returns the
+// notification without its message, the body is never loaded. It is meant for routing
+// decisions on large files. The head is parsed with the handler's parse options, so strict
+// topics and reserved keys behave as for a full read. JSON files have no separate head and
+// are read in full. The file is opened through the file security check if one is set. A file
+// without a rule line has no message and fails with EmptyMessageError.
+func (h *Handler) ReadHead(path string) (*Notification, error) {
+	f, err := openChecked(path, h.parseOptions.Security)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	notif, err := readHead(f, path, h.parseOptions)
+	if err != nil {
+		setErrorFile(err, path)
+		return nil, err
+	}
+	return notif, nil
+}
+
+func readHead(r io.Reader, path string, opts ParseOptions) (*Notification, error) {
+	br := bufio.NewReader(r)
+	if peek, _ := br.Peek(512); isJSON(path, peek) {
+		limit := opts.fileSizeLimit()
+		content, err := io.ReadAll(io.LimitReader(br, limit+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(content)) > limit {
+			return nil, &FileTooLargeError{Limit: limit}
+		}
+		notif, err := parseJSON(content, opts)
+		if err != nil {
+			return nil, err
+		}
+		notif.Message = ""
+		return notif, nil
+	}
+
+	lines := make([]string, 0)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if isRule(line) {
+			break
+		}
+		lines = append(lines, line)
+		if err != nil {
+			if len(cleanHead(lines)) < 1 {
+				return nil, &NoTopicError{}
+			}
+			return nil, &EmptyMessageError{}
+		}
+	}
+
+	lines = cleanHead(lines)
+	if len(lines) < 1 {
+		return nil, &NoTopicError{}
+	}
+	if opts.StrictTopic {
+		if bare := bareLines(lines[1:]); len(bare) > 0 {
+			return nil, &AmbiguousTopicError{Candidates: append([]string{lines[0]}, bare...)}
+		}
+	}
+	notif := &Notification{
+		Topic:    lines[0],
+		Metadata: parseMetadata(lines[1:]),
+	}
+	if err := applyReservedKeys(notif, opts.ReservedKeys); err != nil {
+		return nil, err
+	}
+	return notif, nil
+}
+
+// checkHead fails a file the config of its directory rejects before the body is loaded. Only
+// what can be decided from the head is checked, anything else is left to the full read, so
+// heads that cannot be read yet are not an error here.
+func (h *Handler) checkHead(path string) error {
+	dir := filepath.Dir(path)
+	if cfg := h.dirConfig(dir); cfg == nil || len(cfg.Required) == 0 {
+		return nil
+	}
+	head, err := h.ReadHead(path)
+	if err != nil {
+		return nil
+	}
+	h.enrichFromFilename(head, path)
+	return h.applyDirConfig(head, dir)
+}
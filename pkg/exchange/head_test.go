@@ -0,0 +1,144 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestReadHeadSkipsBody(t *testing.T) {
+	head := "-- routed by topic\nbuilds\nbranch: main\n---\n"
+	body := strings.Repeat("log line that nobody needs for routing\n", 1<<16)
+
+	cr := &countingReader{r: strings.NewReader(head + body)}
+	got, err := readHead(cr, "build.txt", ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Notification{Topic: "builds", Metadata: map[string]string{"branch": "main"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readHead() = %+v, want %+v", got, want)
+	}
+	if cr.n > 4096 {
+		t.Errorf("readHead read %d bytes of a %d byte file", cr.n, len(head)+len(body))
+	}
+}
+
+func TestReadHead(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		opts    []Option
+		want    *Notification
+		wantErr error
+	}{
+		{
+			name:    "topic and metadata",
+			content: "alerts\nhost: a\n---\nmessage\n",
+			want:    &Notification{Topic: "alerts", Metadata: map[string]string{"host": "a"}},
+		},
+		{
+			name:    "crlf",
+			content: "alerts\r\nhost: a\r\n---\r\nmessage\r\n",
+			want:    &Notification{Topic: "alerts", Metadata: map[string]string{"host": "a"}},
+		},
+		{
+			name:    "reserved keys are promoted",
+			content: "alerts\npriority: 7\nhost: a\n---\nmessage\n",
+			want:    &Notification{Topic: "alerts", Metadata: map[string]string{"host": "a"}, Priority: 7},
+		},
+		{
+			name:    "reserved keys rejected",
+			content: "alerts\npriority: 7\n---\nmessage\n",
+			opts:    []Option{WithReservedKeyPolicy(ReservedKeyReject)},
+			wantErr: ErrReservedMetadataKey,
+		},
+		{
+			name:    "strict topic",
+			content: "alerts\nother topic\n---\nmessage\n",
+			opts:    []Option{WithStrictTopic()},
+			wantErr: &AmbiguousTopicError{},
+		},
+		{
+			name:    "json",
+			file:    "alert.json",
+			content: `{"topic": "alerts", "metadata": {"host": "a"}, "message": "message"}`,
+			want:    &Notification{Topic: "alerts", Metadata: map[string]string{"host": "a"}},
+		},
+		{
+			name:    "no rule",
+			content: "alerts\nhost: a\n",
+			wantErr: &EmptyMessageError{},
+		},
+		{
+			name:    "no topic",
+			content: "-- comment\n---\nmessage\n",
+			wantErr: &NoTopicError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, tt.opts...)
+			file := tt.file
+			if file == "" {
+				file = "notif.txt"
+			}
+			path := writeFile(t, h.InputDir, file, tt.content)
+
+			got, err := h.ReadHead(path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) && (err == nil || reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr)) {
+					t.Fatalf("ReadHead() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadHead() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	h := newTestHandler(t)
+	if _, err := h.ReadHead(filepath.Join(h.InputDir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadHead() on missing file error = %v", err)
+	}
+}
+
+func TestRequiredMetadataIsCheckedOnTheHead(t *testing.T) {
+	h := newTestHandler(t, WithMaxFileSize(1024))
+	writeFile(t, h.InputDir, DirConfigName, "required:\n  - job\n")
+	h.loadDirConfig(h.InputDir)
+	body := strings.Repeat("too large to be read\n", 100)
+
+	// The full read would fail on the size limit, the head check fails first.
+	proc := &Process{Filepath: writeFile(t, h.InputDir, "a.txt", "builds\n---\n"+body), Options: h.parseOptions}
+	if err := h.prepare(context.Background(), proc); !errors.Is(err, ErrMissingRequiredMetadata) {
+		t.Errorf("prepare() error = %v, want ErrMissingRequiredMetadata", err)
+	}
+
+	proc = &Process{Filepath: writeFile(t, h.InputDir, "b.txt", "builds\njob: nightly\n---\n"+body), Options: h.parseOptions}
+	var tooLarge *FileTooLargeError
+	if err := h.prepare(context.Background(), proc); !errors.As(err, &tooLarge) {
+		t.Errorf("prepare() error = %v, want FileTooLargeError", err)
+	}
+}
@@ -0,0 +1,53 @@
+package exchange
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Bounds of the backoff between attempts to recreate a removed input directory.
+const (
+	minRewatchDelay = 100 * time.Millisecond
+	maxRewatchDelay = 30 * time.Second
+)
+
+func (h *Handler) isInputDir(path string) bool {
+	return fileKey(path) == fileKey(h.InputDir)
+}
+
+// rewatchInputDir recreates the input directory after it was removed or renamed away and
+// watches it again, retrying with backoff until it succeeds. It reports false if stop was
+// closed first. Files that showed up while nothing was watching are swept up afterwards.
+func (h *Handler) rewatchInputDir(watcher *fsnotify.Watcher, stop <-chan struct{}) bool {
+	slog.Warn("Input directory is gone, recreating it", "dir", h.InputDir)
+	if h.recursive {
+		h.unwatchDir(watcher, h.InputDir)
+	}
+
+	delay := minRewatchDelay
+	for {
+		err := os.MkdirAll(h.InputDir, 0755)
+		if err == nil {
+			err = h.watchTree(watcher, h.InputDir)
+		}
+		if err == nil {
+			break
+		}
+
+		slog.Error("Error recreating input directory", "dir", h.InputDir, "retry", delay, "err", err)
+		select {
+		case <-stop:
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRewatchDelay)
+	}
+
+	slog.Info("Watching input directory again", "dir", h.InputDir)
+	h.loadDirConfig(h.InputDir)
+	h.reconcile()
+	return true
+}
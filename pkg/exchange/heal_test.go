@@ -0,0 +1,36 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestInputDirRecreated(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"flat":      nil,
+		"recursive": {WithRecursive()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newFakeStore()
+			h := newTestHandler(t, append(opts, WithStore(store))...)
+			if err := h.Start(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			writeFile(t, h.InputDir, "before.txt", "topic\n---\nbefore\n")
+			waitFor(t, "file before removal", func() bool { return store.count() == 1 })
+
+			if err := os.RemoveAll(h.InputDir); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, "input dir to be recreated", func() bool {
+				_, err := os.Stat(h.InputDir)
+				return err == nil
+			})
+
+			writeFile(t, h.InputDir, "after.txt", "topic\n---\nafter\n")
+			waitFor(t, "file after recreation", func() bool { return store.count() == 2 })
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// heartbeat periodically inserts a synthetic notification so that a downstream monitor can
+// alert when cland itself stops emitting them.
+type heartbeat struct {
+	topic    string
+	interval time.Duration
+
+	now       func() time.Time
+	newTicker func(time.Duration) (<-chan time.Time, func())
+}
+
+// WithHeartbeat inserts a notification on topic into the store every interval while the
+// handler runs, with the handler's uptime and processed count as metadata. Requires
+// WithStore.
+func WithHeartbeat(topic string, interval time.Duration) Option {
+	return func(h *Handler) {
+		h.heartbeat = &heartbeat{
+			topic:    topic,
+			interval: interval,
+			now:      time.Now,
+			newTicker: func(d time.Duration) (<-chan time.Time, func()) {
+				t := time.NewTicker(d)
+				return t.C, t.Stop
+			},
+		}
+	}
+}
+
+// heartbeatLoop reports the uptime since started, when the handler was started.
+func (h *Handler) heartbeatLoop(ctx context.Context, stop <-chan struct{}, started time.Time) {
+	defer h.loops.Done()
+	hb := h.heartbeat
+	slog.Info("Starting heartbeat", "topic", hb.topic, "interval", hb.interval)
+	tick, stopTicker := hb.newTicker(hb.interval)
+	defer stopTicker()
+
+	for {
+		select {
+		case <-stop:
+			slog.Info("Heartbeat stopped", "topic", hb.topic)
+			return
+		case <-tick:
+			h.beat(ctx, started)
+		}
+	}
+}
+
+func (h *Handler) beat(ctx context.Context, started time.Time) {
+	hb := h.heartbeat
+	_, err := h.store.InsertNotification(ctx, Notification{
+		Topic: hb.topic,
+		Metadata: map[string]string{
+			"uptime":    hb.now().Sub(started).Round(time.Second).String(),
+			"processed": strconv.FormatInt(h.Processed(), 10),
+		},
+		Message: "cland is alive",
+	})
+	if err != nil {
+		slog.Error("Error inserting heartbeat", "topic", hb.topic, "err", err)
+	}
+}
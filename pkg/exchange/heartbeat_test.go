@@ -0,0 +1,122 @@
+package exchange
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	mu       sync.Mutex
+	inserted []Notification
+	notify   chan Notification
+	err      error
+	sent     []int
+	errored  []int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{notify: make(chan Notification, 16)}
+}
+
+func (s *fakeStore) InsertNotification(ctx context.Context, notif Notification) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.inserted = append(s.inserted, notif)
+	s.notify <- notif
+	return len(s.inserted), nil
+}
+
+func (s *fakeStore) MarkNotificationSent(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, id)
+	return nil
+}
+
+func (s *fakeStore) MarkNotificationError(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errored = append(s.errored, id)
+	return nil
+}
+
+func (s *fakeStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.inserted)
+}
+
+type fakeClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	tick chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	c.tick <- now
+}
+
+func (c *fakeClock) NewTicker(time.Duration) (<-chan time.Time, func()) {
+	return c.tick, func() {}
+}
+
+func TestHeartbeat(t *testing.T) {
+	store := newFakeStore()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), tick: make(chan time.Time, 1)}
+
+	h := newTestHandler(t, WithStore(store), WithHeartbeat("cland/heartbeat", time.Minute))
+	h.heartbeat.now = clock.Now
+	h.heartbeat.newTicker = clock.NewTicker
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		h.processed.Store(int64(i * 10))
+		clock.Advance(time.Minute)
+
+		select {
+		case notif := <-store.notify:
+			if notif.Topic != "cland/heartbeat" {
+				t.Errorf("heartbeat topic = %q, want %q", notif.Topic, "cland/heartbeat")
+			}
+			if want := (time.Duration(i) * time.Minute).String(); notif.Metadata["uptime"] != want {
+				t.Errorf("heartbeat uptime = %q, want %q", notif.Metadata["uptime"], want)
+			}
+			if want := []string{"10", "20", "30"}[i-1]; notif.Metadata["processed"] != want {
+				t.Errorf("heartbeat processed = %q, want %q", notif.Metadata["processed"], want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("heartbeat %d was not inserted", i)
+		}
+	}
+
+	if err := h.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case clock.tick <- clock.Now():
+	default:
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if got := store.count(); got != 3 {
+		t.Errorf("heartbeats inserted = %d, want 3 after Stop", got)
+	}
+}
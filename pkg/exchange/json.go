@@ -0,0 +1,52 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+// jsonNotification is the layout of .json input files.
+type jsonNotification struct {
+	Topic    string            `json:"topic"`
+	Metadata map[string]string `json:"metadata"`
+	Message  string            `json:"message"`
+}
+
+// isJSON reports whether a file is in the JSON format, either by its .json extension or
+// because its content starts with an object. A topic line never starts with "{".
+func isJSON(path string, content []byte) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json") || bytes.HasPrefix(bytes.TrimSpace(content), []byte("{"))
+}
+
+// parseJSON decodes a .json input file. Missing topics and messages fail with the same
+// errors as the line based format.
+func parseJSON(content []byte, opts ParseOptions) (*Notification, error) {
+	var decoded jsonNotification
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		return nil, &InvalidJSONError{Err: err}
+	}
+
+	topic := strings.TrimSpace(decoded.Topic)
+	if topic == "" {
+		return nil, &NoTopicError{}
+	}
+	if decoded.Message == "" {
+		return nil, &EmptyMessageError{}
+	}
+
+	metadata := decoded.Metadata
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	notif := &Notification{
+		Topic:    topic,
+		Metadata: metadata,
+		Message:  decoded.Message,
+	}
+	if err := applyReservedKeys(notif, opts.ReservedKeys); err != nil {
+		return nil, err
+	}
+	return notif, nil
+}
@@ -0,0 +1,134 @@
+package exchange
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadFileJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Notification
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			content: `{"topic": "deploys", "metadata": {"host": "web-1", "emoji": "🚀"}, "message": "line one\nline two"}`,
+			want: &Notification{
+				Topic:    "deploys",
+				Metadata: map[string]string{"host": "web-1", "emoji": "🚀"},
+				Message:  "line one\nline two",
+			},
+		},
+		{
+			name:    "no metadata",
+			content: `{"topic": "deploys", "message": "done"}`,
+			want:    &Notification{Topic: "deploys", Metadata: map[string]string{}, Message: "done"},
+		},
+		{
+			name:    "malformed",
+			content: `{"topic": "deploys", "message": `,
+			wantErr: &InvalidJSONError{},
+		},
+		{
+			name:    "non-string metadata",
+			content: `{"topic": "deploys", "metadata": {"count": 3}, "message": "done"}`,
+			wantErr: &InvalidJSONError{},
+		},
+		{
+			name:    "missing topic",
+			content: `{"metadata": {"host": "web-1"}, "message": "done"}`,
+			wantErr: &NoTopicError{},
+		},
+		{
+			name:    "missing message",
+			content: `{"topic": "deploys"}`,
+			wantErr: &EmptyMessageError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "notification.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			p := &Process{Filepath: path}
+			err := p.ReadFile()
+			if tt.wantErr != nil {
+				if reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+					t.Fatalf("ReadFile() error = %v, want %T", err, tt.wantErr)
+				}
+				var jsonErr *InvalidJSONError
+				if errors.As(err, &jsonErr) && jsonErr.File != path {
+					t.Errorf("InvalidJSONError.File = %q, want %q", jsonErr.File, path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if p.Notif.Topic != tt.want.Topic || p.Notif.Message != tt.want.Message ||
+				!reflect.DeepEqual(p.Notif.Metadata, tt.want.Metadata) {
+				t.Errorf("ReadFile() = %+v, want %+v", p.Notif, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFileDetectsFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    *Notification
+		wantErr error
+	}{
+		{
+			name:    "json content without extension",
+			file:    "notification",
+			content: "\n  {\"topic\": \"deploys\", \"message\": \"done\"}\n",
+			want:    &Notification{Topic: "deploys", Metadata: map[string]string{}, Message: "done"},
+		},
+		{
+			name:    "malformed json content in a txt file",
+			file:    "notification.txt",
+			content: "{\"topic\": \"deploys\"\n---\nmessage\n",
+			wantErr: &InvalidJSONError{},
+		},
+		{
+			name:    "line format",
+			file:    "notification.txt",
+			content: "deploys\nhost: web-1\n---\ndone",
+			want:    &Notification{Topic: "deploys", Metadata: map[string]string{"host": "web-1"}, Message: "done"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			p := &Process{Filepath: path}
+			err := p.ReadFile()
+			if tt.wantErr != nil {
+				if reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+					t.Fatalf("ReadFile() error = %v, want %T", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if p.Notif.Topic != tt.want.Topic || p.Notif.Message != tt.want.Message ||
+				!reflect.DeepEqual(p.Notif.Metadata, tt.want.Metadata) {
+				t.Errorf("ReadFile() = %+v, want %+v", p.Notif, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadFileWaitsForCompleteFile(t *testing.T) {
+	h := newTestHandler(t)
+	path := writeFile(t, h.InputDir, "growing.txt", "topic\nhost: nas\n")
+
+	done := make(chan error, 1)
+	proc := &Process{Filepath: path}
+	go func() { done <- proc.ReadFile() }()
+
+	// The first read sees only the head, the producer finishes while ReadFile waits.
+	time.Sleep(READ_FILE_RETRY_DELAY / 2)
+	writeFile(t, h.InputDir, "growing.txt", "topic\nhost: nas\n---\nbackup finished\n")
+
+	if err := <-done; err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if proc.Notif.Topic != "topic" || proc.Notif.Metadata["host"] != "nas" || proc.Notif.Message != "backup finished\n" {
+		t.Errorf("ReadFile() = %+v", proc.Notif)
+	}
+}
+
+func TestLooksComplete(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    bool
+	}{
+		{"complete", "a.txt", "topic\n---\nmessage\n", true},
+		{"no rule", "a.txt", "topic\nkey: value\n", false},
+		{"rule without message", "a.txt", "topic\n---\n", false},
+		{"crlf", "a.txt", "topic\r\n---\r\nmessage", true},
+		{"json", "a.json", `{"topic": "t", "message": "m"}`, true},
+		{"truncated json", "a.json", `{"topic": "t", "mess`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := looksComplete(tt.path, []byte(tt.content)); got != tt.want {
+				t.Errorf("looksComplete() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartialFilesAreIgnored(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store))
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	partial := writeFile(t, h.InputDir, "note.txt.tmp", "topic\n---\nmessage\n")
+	time.Sleep(100 * time.Millisecond)
+	if store.count() != 0 {
+		t.Fatalf("partial file was processed")
+	}
+	if _, err := os.Stat(partial); err != nil {
+		t.Fatalf("partial file was moved: %v", err)
+	}
+
+	if err := os.Rename(partial, filepath.Join(h.InputDir, "note.txt")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "renamed file", func() bool { return store.count() == 1 })
+}
@@ -0,0 +1,79 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandlerPersistsNotifications(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store))
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeFile(t, h.InputDir, "backup.txt", "backups\nhost: nas\n---\nbackup finished")
+
+	stored := <-store.notify
+	if stored.Topic != "backups" || stored.Message != "backup finished" || stored.Metadata["host"] != "nas" {
+		t.Errorf("stored notification = %+v", stored)
+	}
+
+	waitFor(t, "input file to be removed", func() bool { _, err := os.Stat(path); return os.IsNotExist(err) })
+	if _, err := os.Stat(filepath.Join(h.ErrorDir, "backup.txt")); err == nil {
+		t.Errorf("stored file ended up in the error dir")
+	}
+}
+
+func TestHandlerRoutesStoreFailuresToErrorDir(t *testing.T) {
+	store := newFakeStore()
+	store.err = errors.New("database is closed")
+	h := newTestHandler(t, WithStore(store))
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeFile(t, h.InputDir, "backup.txt", "backups\n---\nbackup finished")
+
+	waitFor(t, "file in error dir", func() bool { _, err := os.Stat(filepath.Join(h.ErrorDir, "backup.txt")); return err == nil })
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("failed file is still in the input dir")
+	}
+	if got := h.Processed(); got != 0 {
+		t.Errorf("Processed() = %d, want 0", got)
+	}
+}
+
+func TestHandlerSkipsDuplicateFiles(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store))
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	content := "dedup\n---\nsame content twice"
+
+	first := writeFile(t, h.InputDir, "first.txt", content)
+	<-store.notify
+	waitFor(t, "first file to be stored", func() bool { _, err := os.Stat(first); return os.IsNotExist(err) && h.Processed() == 1 })
+
+	// What a store with a dedup window answers for the same content.
+	store.mu.Lock()
+	store.err = ErrDuplicateNotification
+	store.mu.Unlock()
+
+	second := writeFile(t, h.InputDir, "second.txt", content)
+	waitFor(t, "duplicate to be consumed", func() bool { _, err := os.Stat(second); return os.IsNotExist(err) })
+	if _, err := os.Stat(filepath.Join(h.ErrorDir, "second.txt")); err == nil {
+		t.Errorf("duplicate ended up in the error dir")
+	}
+
+	if got := store.count(); got != 1 {
+		t.Errorf("stored %d notifications, want 1", got)
+	}
+	if got := h.Processed(); got != 1 {
+		t.Errorf("Processed() = %d, want 1", got)
+	}
+}
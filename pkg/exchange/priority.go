@@ -0,0 +1,55 @@
+package exchange
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PriorityMetadataKey is the head key holding a notification's priority, either a number
+// promoted to Notification.Priority or a label.
+const PriorityMetadataKey = "priority"
+
+var priorityLabelPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// errorDirFor returns the directory a failed file goes to. Files whose notification was
+// parsed and carries a priority label or a non-zero priority go to a subdirectory named
+// after it, e.g. error/critical/ or error/7/, everything else stays in the flat ErrorDir.
+func (h *Handler) errorDirFor(p *Process) string {
+	if p.Notif == nil {
+		return h.ErrorDir
+	}
+	label := strings.ToLower(strings.TrimSpace(p.Notif.Metadata[PriorityMetadataKey]))
+	if label == "" && p.Notif.Priority > 0 {
+		label = strconv.Itoa(p.Notif.Priority)
+	}
+	if !priorityLabelPattern.MatchString(label) {
+		return h.ErrorDir
+	}
+
+	dir := filepath.Join(h.ErrorDir, label)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Error("Error creating priority error dir, using flat error dir", "dir", dir, "err", err)
+		return h.ErrorDir
+	}
+	return dir
+}
+
+// errorDirs returns ErrorDir followed by the priority subdirectories errorDirFor created in
+// it, sorted by name.
+func (h *Handler) errorDirs() ([]string, error) {
+	entries, err := os.ReadDir(h.ErrorDir)
+	if err != nil {
+		return nil, err
+	}
+	dirs := []string{h.ErrorDir}
+	for _, entry := range entries {
+		if entry.IsDir() && priorityLabelPattern.MatchString(entry.Name()) {
+			dirs = append(dirs, filepath.Join(h.ErrorDir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
@@ -0,0 +1,83 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPriorityErrorDirs(t *testing.T) {
+	store := newFakeStore()
+	store.err = errors.New("database is locked")
+	h := newTestHandler(t, WithStore(store))
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, h.InputDir, "critical.txt", "alerts\npriority: critical\n---\ndisk full\n")
+	writeFile(t, h.InputDir, "nopriority.txt", "alerts\n---\ndisk full\n")
+	writeFile(t, h.InputDir, "numeric.txt", "alerts\npriority: 7\n---\ndisk full\n")
+	writeFile(t, h.InputDir, "weird.txt", "alerts\npriority: ../../etc\n---\ndisk full\n")
+	// Parse failures happen before the priority is known.
+	writeFile(t, h.InputDir, "broken.txt", "priority: critical\n---\n")
+
+	for _, path := range []string{
+		filepath.Join(h.ErrorDir, "critical", "critical.txt"),
+		filepath.Join(h.ErrorDir, "nopriority.txt"),
+		filepath.Join(h.ErrorDir, "7", "numeric.txt"),
+		filepath.Join(h.ErrorDir, "weird.txt"),
+		filepath.Join(h.ErrorDir, "broken.txt"),
+	} {
+		waitFor(t, path, func() bool {
+			_, err := os.Stat(path)
+			return err == nil
+		})
+	}
+	if got := h.Processed(); got != 0 {
+		t.Errorf("Processed() = %d although every insert failed", got)
+	}
+}
+
+func TestPriorityPromotion(t *testing.T) {
+	tests := []struct {
+		value    string
+		priority int
+		metadata map[string]string
+	}{
+		{value: "7", priority: 7, metadata: map[string]string{}},
+		{value: " 0 ", priority: 0, metadata: map[string]string{}},
+		{value: "critical", priority: 0, metadata: map[string]string{"priority": "critical"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			notif, err := parse([]string{"topic", "priority: " + tt.value, "---", "message"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if notif.Priority != tt.priority {
+				t.Errorf("Priority = %d, want %d", notif.Priority, tt.priority)
+			}
+			if !reflect.DeepEqual(notif.Metadata, tt.metadata) {
+				t.Errorf("Metadata = %v, want %v", notif.Metadata, tt.metadata)
+			}
+		})
+	}
+}
+
+func TestStoreInsertsParsedNotifications(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store))
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, h.InputDir, "ok.txt", "alerts\n---\ndisk full\n")
+	notif := <-store.notify
+	if notif.Topic != "alerts" || notif.Message != "disk full\n" {
+		t.Errorf("inserted %+v", notif)
+	}
+	waitFor(t, "file to be counted", func() bool { return h.Processed() == 1 })
+}
@@ -0,0 +1,73 @@
+package exchange
+
+import "log/slog"
+
+// ProcessCapPolicy decides what happens to a new file once the live Process cap is reached.
+type ProcessCapPolicy int
+
+const (
+	// ProcessCapBlock stalls the watcher until a Process is returned to the pool.
+	ProcessCapBlock ProcessCapPolicy = iota
+	// ProcessCapReject moves the file straight to the error directory.
+	ProcessCapReject
+)
+
+// WithMaxLiveProcesses caps the number of Process objects taken from the pool at any time,
+// bounding memory even if processing stalls. Files arriving past the cap are handled
+// according to policy.
+func WithMaxLiveProcesses(max int, policy ProcessCapPolicy) Option {
+	return func(h *Handler) {
+		if max > 0 {
+			h.processSlots = make(chan struct{}, max)
+			h.processCapPolicy = policy
+		}
+	}
+}
+
+// WithMaxConcurrency lets at most n files be read, parsed and stored at the same time.
+// Unlike WithMaxLiveProcesses it never stalls the watcher, files past the limit wait for
+// a free slot in their own goroutine.
+func WithMaxConcurrency(n int) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.workSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+func (h *Handler) acquireWorkSlot() {
+	if h.workSlots != nil {
+		h.workSlots <- struct{}{}
+	}
+}
+
+func (h *Handler) releaseWorkSlot() {
+	if h.workSlots != nil {
+		<-h.workSlots
+	}
+}
+
+// reserveProcess takes a Process slot and reports false if the file was rejected.
+func (h *Handler) reserveProcess(path string) bool {
+	if h.processSlots == nil {
+		return true
+	}
+	if h.processCapPolicy == ProcessCapReject {
+		select {
+		case h.processSlots <- struct{}{}:
+			return true
+		default:
+			slog.Warn("Live process cap reached, rejecting file", "file", path, "cap", cap(h.processSlots))
+			h.moveToError(&Process{Filepath: path}, ErrProcessCapReached)
+			return false
+		}
+	}
+	h.processSlots <- struct{}{}
+	return true
+}
+
+func (h *Handler) releaseProcess() {
+	if h.processSlots != nil {
+		<-h.processSlots
+	}
+}
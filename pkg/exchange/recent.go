@@ -0,0 +1,61 @@
+package exchange
+
+import "sync"
+
+// WithRecentBuffer keeps the last size parsed notifications in memory so they can be read
+// back through RecentNotifications without touching the store. Older entries are dropped.
+func WithRecentBuffer(size int) Option {
+	return func(h *Handler) {
+		if size > 0 {
+			h.recent = &recentBuffer{entries: make([]Notification, size)}
+		}
+	}
+}
+
+// recentBuffer is a fixed size ring of notifications.
+type recentBuffer struct {
+	mu      sync.Mutex
+	entries []Notification
+	next    int
+	count   int
+}
+
+func (r *recentBuffer) add(notif Notification) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = notif
+	r.next = (r.next + 1) % len(r.entries)
+	if r.count < len(r.entries) {
+		r.count++
+	}
+}
+
+// last returns up to n entries, oldest first.
+func (r *recentBuffer) last(n int) []Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n > r.count {
+		n = r.count
+	}
+	notifs := make([]Notification, 0, n)
+	start := r.next - n + len(r.entries)
+	for i := 0; i < n; i++ {
+		notifs = append(notifs, r.entries[(start+i)%len(r.entries)])
+	}
+	return notifs
+}
+
+func (h *Handler) remember(notif *Notification) {
+	if h.recent != nil {
+		h.recent.add(*notif)
+	}
+}
+
+// RecentNotifications returns up to the n most recently parsed notifications, oldest first.
+// It returns nil if the handler was created without WithRecentBuffer.
+func (h *Handler) RecentNotifications(n int) []Notification {
+	if h.recent == nil || n <= 0 {
+		return nil
+	}
+	return h.recent.last(n)
+}
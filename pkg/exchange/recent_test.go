@@ -0,0 +1,72 @@
+package exchange
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRecentNotifications(t *testing.T) {
+	h := newTestHandler(t, WithRecentBuffer(3))
+
+	if got := h.RecentNotifications(3); len(got) != 0 {
+		t.Fatalf("RecentNotifications on empty buffer = %v", got)
+	}
+
+	for i := 1; i <= 5; i++ {
+		h.remember(&Notification{Topic: "t", Message: fmt.Sprintf("m%d", i)})
+	}
+
+	messages := func(notifs []Notification) []string {
+		out := make([]string, 0, len(notifs))
+		for _, n := range notifs {
+			out = append(out, n.Message)
+		}
+		return out
+	}
+
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{n: 3, want: []string{"m3", "m4", "m5"}},
+		{n: 10, want: []string{"m3", "m4", "m5"}},
+		{n: 2, want: []string{"m4", "m5"}},
+		{n: 0, want: []string{}},
+	}
+	for _, tt := range tests {
+		if got := messages(h.RecentNotifications(tt.n)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RecentNotifications(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRecentNotificationsConcurrent(t *testing.T) {
+	h := newTestHandler(t, WithRecentBuffer(16))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.remember(&Notification{Topic: "t", Message: "m"})
+				h.RecentNotifications(8)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(h.RecentNotifications(100)); got != 16 {
+		t.Errorf("buffer holds %d notifications, want 16", got)
+	}
+}
+
+func TestRecentNotificationsDisabled(t *testing.T) {
+	h := newTestHandler(t)
+	h.remember(&Notification{Topic: "t", Message: "m"})
+	if got := h.RecentNotifications(1); got != nil {
+		t.Errorf("RecentNotifications without buffer = %v, want nil", got)
+	}
+}
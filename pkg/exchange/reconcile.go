@@ -0,0 +1,114 @@
+package exchange
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithReconcileInterval periodically scans the input directory and processes files that
+// never produced a Create event, e.g. because fsnotify dropped events under a burst.
+func WithReconcileInterval(interval time.Duration) Option {
+	return func(h *Handler) {
+		h.reconcileInterval = interval
+	}
+}
+
+func (h *Handler) reconcileLoop(stop <-chan struct{}) {
+	defer h.loops.Done()
+	ticker := time.NewTicker(h.reconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			h.reconcile()
+		}
+	}
+}
+
+func (h *Handler) reconcile() {
+	files := h.inputFiles(h.InputDir)
+
+	present := make(map[string]bool, len(files))
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		key := fileKey(path)
+		present[key] = true
+		if h.handled(key, info.ModTime()) {
+			continue
+		}
+
+		slog.Info("Reconciling unannounced file", "file", path)
+		h.process(path)
+	}
+
+	h.mu.Lock()
+	for key := range h.seen {
+		if !present[key] {
+			delete(h.seen, key)
+		}
+	}
+	h.mu.Unlock()
+}
+
+func fileKey(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// begin marks path as in flight. It reports false if the file is already in flight, was
+// already handled in its current version or is gone, e.g. because a Create event arrived
+// after the startup scan had already processed and consumed the file.
+func (h *Handler) begin(path string) (string, bool) {
+	key := fileKey(path)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return key, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.active[key] {
+		return key, false
+	}
+	if seen, ok := h.seen[key]; ok && err == nil && seen.Equal(info.ModTime()) {
+		return key, false
+	}
+	h.active[key] = true
+	return key, true
+}
+
+// finish clears the in-flight mark and remembers files that stayed in the input dir so
+// the reconciliation sweep does not pick them up again.
+func (h *Handler) finish(key, path string) {
+	info, err := os.Stat(path)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.active, key)
+	if err == nil {
+		h.seen[key] = info.ModTime()
+	} else {
+		delete(h.seen, key)
+	}
+}
+
+func (h *Handler) handled(key string, modTime time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.active[key] {
+		return true
+	}
+	seen, ok := h.seen[key]
+	return ok && seen.Equal(modTime)
+}
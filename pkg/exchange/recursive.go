@@ -0,0 +1,149 @@
+package exchange
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WithRecursive also watches every subdirectory of the input directory, including ones
+// created while the handler runs. Files anywhere in the tree are processed like files at
+// the top level and each directory can have its own DirConfigName.
+func WithRecursive() Option {
+	return func(h *Handler) {
+		h.recursive = true
+	}
+}
+
+// watchTree adds root and, for recursive handlers, every directory below it to watcher.
+// Only failing to watch root itself is an error.
+func (h *Handler) watchTree(watcher *fsnotify.Watcher, root string) error {
+	if !h.recursive {
+		return watcher.Add(root)
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			slog.Warn("Error walking input tree", "path", path, "err", err)
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && h.isOutputDir(path) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			if path == root {
+				return err
+			}
+			slog.Error("Error watching directory", "dir", path, "err", err)
+			return nil
+		}
+		h.mu.Lock()
+		h.watchedDirs[fileKey(path)] = true
+		h.mu.Unlock()
+		if path != root {
+			h.loadDirConfig(path)
+		}
+		return nil
+	})
+}
+
+// isOutputDir reports whether dir is the error or done directory, which may live inside
+// the input tree but must never be watched.
+func (h *Handler) isOutputDir(dir string) bool {
+	key := fileKey(dir)
+	return key == fileKey(h.ErrorDir) || (h.DoneDir != "" && key == fileKey(h.DoneDir))
+}
+
+// watchNewDir starts watching a directory created after Start and processes the files
+// that were written into it before the watch was in place.
+func (h *Handler) watchNewDir(watcher *fsnotify.Watcher, dir string) {
+	if h.isOutputDir(dir) {
+		return
+	}
+	slog.Info("Watching new directory", "dir", dir)
+	if err := h.watchTree(watcher, dir); err != nil {
+		slog.Error("Error watching directory", "dir", dir, "err", err)
+		return
+	}
+	for _, path := range h.inputFiles(dir) {
+		h.process(path)
+	}
+}
+
+// unwatchDir drops the watches of a removed or renamed directory and everything below it.
+func (h *Handler) unwatchDir(watcher *fsnotify.Watcher, dir string) {
+	key := fileKey(dir)
+	h.mu.Lock()
+	var removed []string
+	for watched := range h.watchedDirs {
+		if watched == key || strings.HasPrefix(watched, key+string(filepath.Separator)) {
+			removed = append(removed, watched)
+			delete(h.watchedDirs, watched)
+			delete(h.dirConfigs, watched)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, watched := range removed {
+		slog.Info("Directory removed, no longer watching it", "dir", watched)
+		// Watches of deleted directories are already gone, only renamed ones remain.
+		_ = watcher.Remove(watched)
+	}
+}
+
+func (h *Handler) isWatchedDir(path string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.watchedDirs[fileKey(path)]
+}
+
+// inputFiles lists the regular files to process in dir, and for recursive handlers in
+// all directories below it.
+func (h *Handler) inputFiles(dir string) []string {
+	files := make([]string, 0)
+	if !h.recursive {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			slog.Error("Error reading input dir", "dir", dir, "err", err)
+			return files
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !isIgnoredFile(entry.Name()) {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+		return files
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && h.isOutputDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && !isIgnoredFile(d.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Error reading input dir", "dir", dir, "err", err)
+	}
+	return files
+}
@@ -0,0 +1,65 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecursiveWatch(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store), WithRecursive())
+	existing := filepath.Join(h.InputDir, "existing")
+	if err := os.MkdirAll(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, existing, "a.txt", "topic\n---\nin existing dir\n")
+	waitFor(t, "file in existing subdir", func() bool { return store.count() == 1 })
+
+	nested := filepath.Join(h.InputDir, "new", "nested")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "nested dir to be watched", func() bool { return h.isWatchedDir(nested) })
+	path := writeFile(t, nested, "b.txt", "topic\n---\nin new dir\n")
+	waitFor(t, "file in new subdir", func() bool { return store.count() == 2 })
+	waitFor(t, "file to be consumed", func() bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	})
+
+	if err := os.RemoveAll(filepath.Join(h.InputDir, "new")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "removed dirs to be unwatched", func() bool {
+		return !h.isWatchedDir(nested) && !h.isWatchedDir(filepath.Join(h.InputDir, "new"))
+	})
+}
+
+func TestFlatWatchIgnoresSubdirectories(t *testing.T) {
+	store := newFakeStore()
+	h := newTestHandler(t, WithStore(store))
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := filepath.Join(h.InputDir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, sub, "a.txt", "topic\n---\nmessage\n")
+	writeFile(t, h.InputDir, "b.txt", "topic\n---\nmessage\n")
+	waitFor(t, "top-level file", func() bool { return store.count() == 1 })
+
+	if _, err := os.Stat(filepath.Join(sub, "a.txt")); err != nil {
+		t.Errorf("file in subdir was touched: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(h.ErrorDir, "sub")); err == nil {
+		t.Errorf("subdir was moved to the error dir")
+	}
+}
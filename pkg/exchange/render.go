@@ -0,0 +1,50 @@
+package exchange
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+type Format string
+
+const (
+	FormatPlain    Format = "plain"
+	FormatMarkdown Format = "markdown"
+)
+
+var htmlPolicy = bluemonday.UGCPolicy()
+
+// detectFormat prefers an explicit `format:` metadata line over the file extension.
+func detectFormat(path string, metadata map[string]string) Format {
+	switch strings.ToLower(metadata["format"]) {
+	case "markdown", "md":
+		return FormatMarkdown
+	case "plain", "text", "txt":
+		return FormatPlain
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	}
+	return FormatPlain
+}
+
+func (n *Notification) RenderHTML() (string, error) {
+	if n.Format != FormatMarkdown {
+		escaped := html.EscapeString(n.Message)
+		return "<p>" + strings.ReplaceAll(escaped, "\n", "<br>\n") + "</p>", nil
+	}
+
+	var buf bytes.Buffer
+	if err := goldmark.Convert([]byte(n.Message), &buf); err != nil {
+		return "", fmt.Errorf("failed to render markdown: %w", err)
+	}
+	return htmlPolicy.Sanitize(buf.String()), nil
+}
@@ -0,0 +1,92 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		metadata map[string]string
+		want     Format
+	}{
+		{name: "txt", path: "in/note.txt", want: FormatPlain},
+		{name: "no extension", path: "in/note", want: FormatPlain},
+		{name: "md extension", path: "in/note.md", want: FormatMarkdown},
+		{name: "markdown extension", path: "in/note.MARKDOWN", want: FormatMarkdown},
+		{name: "metadata", path: "in/note.txt", metadata: map[string]string{"format": "markdown"}, want: FormatMarkdown},
+		{name: "metadata overrides extension", path: "in/note.md", metadata: map[string]string{"format": "plain"}, want: FormatPlain},
+		{name: "unknown metadata", path: "in/note.md", metadata: map[string]string{"format": "rtf"}, want: FormatMarkdown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFormat(tt.path, tt.metadata); got != tt.want {
+				t.Errorf("detectFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	tests := []struct {
+		name       string
+		notif      Notification
+		contains   []string
+		notContain []string
+	}{
+		{
+			name: "markdown header and link",
+			notif: Notification{
+				Format:  FormatMarkdown,
+				Message: "# Deploy\n\nSee [the logs](https://example.com/logs).",
+			},
+			contains: []string{"<h1>Deploy</h1>", `<a href="https://example.com/logs"`, "the logs</a>"},
+		},
+		{
+			name: "markdown script injection",
+			notif: Notification{
+				Format:  FormatMarkdown,
+				Message: "hello <script>alert(1)</script>\n\n[click](javascript:alert(1))",
+			},
+			contains:   []string{"hello"},
+			notContain: []string{"<script", "javascript:"},
+		},
+		{
+			name: "plain text is escaped",
+			notif: Notification{
+				Message: "a < b && <script>alert(1)</script>\nsecond line",
+			},
+			contains:   []string{"<p>", "a &lt; b &amp;&amp; &lt;script&gt;", "<br>\nsecond line", "</p>"},
+			notContain: []string{"<script"},
+		},
+		{
+			name: "plain text ignores markdown",
+			notif: Notification{
+				Format:  FormatPlain,
+				Message: "# not a header",
+			},
+			contains:   []string{"<p># not a header</p>"},
+			notContain: []string{"<h1>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.notif.RenderHTML()
+			if err != nil {
+				t.Fatalf("RenderHTML() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("RenderHTML() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, unwanted := range tt.notContain {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("RenderHTML() = %q, want it to not contain %q", got, unwanted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reprocess synchronously runs a single file through the parse and insert pipeline and
+// returns the result, its ID is set once it was stored. A file whose notification was stored
+// or turned out to be a duplicate is consumed like any other. A file that fails again is
+// moved to the error dir if it came from the input dir and left where it is otherwise. It
+// emits the same events as a file picked up by the watcher. name must be a plain file name,
+// it is looked up in the input dir, the error dir and the error dir's priority
+// subdirectories in that order. Paths that would leave these directories are rejected.
+func (h *Handler) Reprocess(ctx context.Context, name string) (*Notification, error) {
+	path, err := h.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := h.claim(path)
+	if !ok {
+		return nil, ErrFileBusy
+	}
+	defer h.finish(key, path)
+
+	slog.Info("Reprocessing file", "file", path)
+	proc := &Process{Filepath: path, Options: h.parseOptions}
+	if err := h.prepare(ctx, proc); err != nil {
+		h.emit(path, EventParseError, err)
+		h.failReprocess(proc, err)
+		return nil, err
+	}
+	h.emit(path, EventParsed, nil)
+
+	err = h.persist(ctx, proc.Notif)
+	if errors.Is(err, ErrDuplicateNotification) {
+		slog.Info("Skipping duplicate notification", "file", path, "topic", proc.Notif.Topic)
+		h.consume(path)
+		h.emit(path, EventDuplicate, err)
+		return proc.Notif, nil
+	}
+	if err != nil {
+		h.emit(path, EventStoreError, err)
+		h.failReprocess(proc, err)
+		return nil, err
+	}
+
+	h.processed.Add(1)
+	h.remember(proc.Notif)
+	h.consume(path)
+	h.deliver(ctx, proc.Notif)
+	h.emit(path, EventStored, nil)
+	return proc.Notif, nil
+}
+
+// failReprocess moves a file that failed again from the input dir to the error dir. Files
+// already in an error dir stay there. Both end with EventMovedToError.
+func (h *Handler) failReprocess(proc *Process, cause error) {
+	if filepath.Dir(proc.Filepath) != filepath.Clean(h.InputDir) {
+		h.emit(proc.Filepath, EventMovedToError, cause)
+		return
+	}
+	h.moveToError(proc, cause)
+}
+
+// claim marks path as in flight unless the watcher is already working on it. Unlike begin
+// it ignores whether the file was seen before, reprocessing is an explicit request.
+func (h *Handler) claim(path string) (string, bool) {
+	key := fileKey(path)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.active[key] {
+		return key, false
+	}
+	h.active[key] = true
+	return key, true
+}
+
+func (h *Handler) resolve(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || filepath.Base(name) != name {
+		return "", ErrInvalidPath
+	}
+
+	dirs := []string{h.InputDir, h.ErrorDir}
+	if errorDirs, err := h.errorDirs(); err == nil {
+		dirs = append(dirs, errorDirs[1:]...)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		return path, nil
+	}
+	return "", ErrFileNotFound
+}
@@ -0,0 +1,110 @@
+package exchange
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReservedKeyPolicy int
+
+const (
+	// ReservedKeyPromote moves reserved keys into their typed notification field. Keys
+	// without a typed field yet are kept in the metadata.
+	ReservedKeyPromote ReservedKeyPolicy = iota
+	// ReservedKeyIgnore drops reserved keys from the metadata.
+	ReservedKeyIgnore
+	// ReservedKeyReject fails the file with ErrReservedMetadataKey.
+	ReservedKeyReject
+)
+
+// reservedKeys lists the head keys that are, or will become, typed notification fields.
+// A non-nil promoter sets the typed field from the metadata value and reports whether it
+// did, keys it does not promote stay in the metadata.
+var reservedKeys = map[string]func(n *Notification, value string) (bool, error){
+	"priority":        promotePriority,
+	"send_at":         promoteSendAt,
+	"idempotency_key": promoteIdempotencyKey,
+	"severity":        nil,
+	"deliver_at":      nil,
+	"expires_at":      nil,
+	"title":           nil,
+	"from":            nil,
+	"tags":            nil,
+	"target":          nil,
+}
+
+// promotePriority sets numeric priorities. Other values are labels, which only route failed
+// files to their error subdirectory, and stay in the metadata.
+func promotePriority(n *Notification, value string) (bool, error) {
+	priority, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return false, nil
+	}
+	n.Priority = priority
+	return true, nil
+}
+
+// sendAtLayouts are tried in order, layouts without a zone are read as local time.
+var sendAtLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+func promoteSendAt(n *Notification, value string) (bool, error) {
+	value = strings.TrimSpace(value)
+	var err error
+	for _, layout := range sendAtLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			n.SendAt = t
+			return true, nil
+		}
+	}
+	return false, &InvalidTimestampError{Key: "send_at", Value: value, Err: err}
+}
+
+func promoteIdempotencyKey(n *Notification, value string) (bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false, nil
+	}
+	n.IdempotencyKey = value
+	return true, nil
+}
+
+func IsReservedKey(key string) bool {
+	_, ok := reservedKeys[key]
+	return ok
+}
+
+func applyReservedKeys(notif *Notification, policy ReservedKeyPolicy) error {
+	keys := make([]string, 0)
+	for key := range notif.Metadata {
+		if IsReservedKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch policy {
+		case ReservedKeyReject:
+			return fmt.Errorf("%w: %s", ErrReservedMetadataKey, key)
+		case ReservedKeyIgnore:
+			delete(notif.Metadata, key)
+		default:
+			promote := reservedKeys[key]
+			if promote == nil {
+				continue
+			}
+			promoted, err := promote(notif, notif.Metadata[key])
+			if err != nil {
+				return err
+			}
+			if promoted {
+				delete(notif.Metadata, key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package exchange
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReservedKeyPolicy(t *testing.T) {
+	lines := []string{
+		"topic",
+		"title: Disk full",
+		"host: a",
+		"---",
+		"message",
+	}
+
+	tests := []struct {
+		name    string
+		policy  ReservedKeyPolicy
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name:   "promote keeps keys without typed field",
+			policy: ReservedKeyPromote,
+			want:   map[string]string{"title": "Disk full", "host": "a"},
+		},
+		{
+			name:   "ignore",
+			policy: ReservedKeyIgnore,
+			want:   map[string]string{"host": "a"},
+		},
+		{
+			name:    "reject",
+			policy:  ReservedKeyReject,
+			wantErr: ErrReservedMetadataKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWithOptions(lines, ParseOptions{ReservedKeys: tt.policy})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parseWithOptions() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseWithOptions() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Metadata, tt.want) {
+				t.Errorf("metadata = %v, want %v", got.Metadata, tt.want)
+			}
+		})
+	}
+
+	t.Run("no reserved keys", func(t *testing.T) {
+		for _, policy := range []ReservedKeyPolicy{ReservedKeyPromote, ReservedKeyIgnore, ReservedKeyReject} {
+			got, err := parseWithOptions([]string{"topic", "host: a", "---", "message"}, ParseOptions{ReservedKeys: policy})
+			if err != nil {
+				t.Fatalf("policy %d: unexpected error = %v", policy, err)
+			}
+			if !reflect.DeepEqual(got.Metadata, map[string]string{"host": "a"}) {
+				t.Errorf("policy %d: metadata = %v", policy, got.Metadata)
+			}
+		}
+	})
+}
+
+func TestSendAtPromotion(t *testing.T) {
+	notif, err := parse([]string{"reminders", "send_at: 2030-01-02T15:04:05Z", "---", "stand up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC); !notif.SendAt.Equal(want) {
+		t.Errorf("SendAt = %v, want %v", notif.SendAt, want)
+	}
+	if _, ok := notif.Metadata["send_at"]; ok {
+		t.Errorf("send_at left in metadata")
+	}
+
+	notif, err = parse([]string{"reminders", "send_at: 2030-01-02 15:04:05", "---", "stand up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2030, 1, 2, 15, 4, 5, 0, time.Local); !notif.SendAt.Equal(want) {
+		t.Errorf("SendAt = %v, want %v in local time", notif.SendAt, want)
+	}
+
+	_, err = parse([]string{"reminders", "send_at: tomorrow", "---", "stand up"})
+	var tsErr *InvalidTimestampError
+	if !errors.As(err, &tsErr) {
+		t.Fatalf("parse() error = %v, want an InvalidTimestampError", err)
+	}
+	if tsErr.Key != "send_at" || tsErr.Value != "tomorrow" {
+		t.Errorf("InvalidTimestampError = %+v", tsErr)
+	}
+}
+
+func TestIdempotencyKeyPromotion(t *testing.T) {
+	notif, err := parse([]string{"jobs", "idempotency_key: job-42 ", "---", "done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notif.IdempotencyKey != "job-42" {
+		t.Errorf("IdempotencyKey = %q, want job-42", notif.IdempotencyKey)
+	}
+	if _, ok := notif.Metadata["idempotency_key"]; ok {
+		t.Errorf("idempotency_key left in metadata")
+	}
+}
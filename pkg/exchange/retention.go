@@ -0,0 +1,84 @@
+package exchange
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const ERROR_RETENTION_SWEEP_INTERVAL = time.Hour
+
+// WithErrorRetention deletes files that have been in the error directory or one of its
+// priority subdirectories for longer than maxAge. The sweep runs when the handler starts and
+// then every ERROR_RETENTION_SWEEP_INTERVAL. Files moved out with TakeErrorFiles are no longer
+// in ErrorDir and are never pruned.
+func WithErrorRetention(maxAge time.Duration) Option {
+	return func(h *Handler) {
+		h.errorRetention = maxAge
+	}
+}
+
+func (h *Handler) retentionLoop(stop <-chan struct{}) {
+	defer h.loops.Done()
+	h.pruneErrorFiles(time.Now())
+	ticker := time.NewTicker(ERROR_RETENTION_SWEEP_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			h.pruneErrorFiles(now)
+		}
+	}
+}
+
+// pruneErrorFiles deletes error files last modified more than errorRetention before now.
+// It holds takeMu so that it never races TakeErrorFiles over the same files.
+func (h *Handler) pruneErrorFiles(now time.Time) {
+	h.takeMu.Lock()
+	defer h.takeMu.Unlock()
+
+	dirs, err := h.errorDirs()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("Error reading error dir", "err", err)
+		}
+		return
+	}
+
+	pruned := 0
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || now.Sub(info.ModTime()) <= h.errorRetention {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			h.mu.Lock()
+			active := h.active[fileKey(path)]
+			h.mu.Unlock()
+			if active {
+				// Being reprocessed right now.
+				continue
+			}
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				slog.Error("Error pruning error file", "file", path, "err", err)
+				continue
+			}
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		slog.Info("Pruned error files", "count", pruned, "maxAge", h.errorRetention)
+	}
+}
@@ -0,0 +1,60 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"time"
+)
+
+const (
+	RETRY_BASE_DELAY = 100 * time.Millisecond
+	RETRY_MAX_DELAY  = 10 * time.Second
+)
+
+// WithMaxRetries retries reading and storing a file up to n times, with exponential backoff,
+// if it fails for a reason that may go away on its own: an I/O error while reading it or
+// any error from the store but ErrInvalidNotification. Parse errors fail right away.
+// Without it transient failures move the file to the error directory like any other.
+func WithMaxRetries(n int) Option {
+	return func(h *Handler) {
+		h.maxRetries = n
+	}
+}
+
+// retry runs step until it succeeds, fails permanently or the retries are used up, and
+// returns its last error. Every retry is logged and emitted as EventRetrying.
+func (h *Handler) retry(ctx context.Context, path string, step func() error, transient func(error) bool) error {
+	delay := RETRY_BASE_DELAY
+	for attempt := 1; ; attempt++ {
+		err := step()
+		if err == nil || attempt > h.maxRetries || !transient(err) {
+			return err
+		}
+
+		slog.Warn("Transient error, retrying", "file", path, "attempt", attempt, "maxRetries", h.maxRetries, "retryIn", delay, "err", err)
+		if h.onEvent != nil {
+			h.onEvent(Event{Path: path, Outcome: EventRetrying, Err: err, Attempt: attempt})
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, RETRY_MAX_DELAY)
+	}
+}
+
+// isTransientReadError reports whether reading a file failed for a reason other than its
+// content. A file that is gone will not come back.
+func isTransientReadError(err error) bool {
+	var pathErr *fs.PathError
+	return errors.As(err, &pathErr) && !errors.Is(err, fs.ErrNotExist)
+}
+
+// isTransientStoreError treats every store error as transient unless the store marked the
+// notification as a duplicate or as invalid.
+func isTransientStoreError(err error) bool {
+	return !errors.Is(err, ErrDuplicateNotification) && !errors.Is(err, ErrInvalidNotification)
+}
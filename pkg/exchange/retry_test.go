@@ -0,0 +1,123 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// flakyStore fails the first failures inserts with err, errStoreDown if it is nil, and
+// stores everything after that.
+type flakyStore struct {
+	*fakeStore
+	mu       sync.Mutex
+	failures int
+	err      error
+	attempts int
+}
+
+var (
+	errStoreDown = errors.New("store is down")
+	errTooLong   = fmt.Errorf("%w: message exceeds maximum length", ErrInvalidNotification)
+)
+
+func (s *flakyStore) InsertNotification(ctx context.Context, notif Notification) (int, error) {
+	s.mu.Lock()
+	s.attempts++
+	if s.failures > 0 {
+		s.failures--
+		s.mu.Unlock()
+		if s.err != nil {
+			return 0, s.err
+		}
+		return 0, errStoreDown
+	}
+	s.mu.Unlock()
+	return s.fakeStore.InsertNotification(ctx, notif)
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		failures   int
+		err        error
+		maxRetries int
+		want       []EventOutcome
+	}{
+		{
+			name:       "store recovers",
+			content:    "topic\n---\nmessage\n",
+			failures:   2,
+			maxRetries: 3,
+			want:       []EventOutcome{EventParsed, EventRetrying, EventRetrying, EventStored},
+		},
+		{
+			name:       "retries used up",
+			content:    "topic\n---\nmessage\n",
+			failures:   5,
+			maxRetries: 2,
+			want:       []EventOutcome{EventParsed, EventRetrying, EventRetrying, EventStoreError, EventMovedToError},
+		},
+		{
+			name:       "parse errors fail fast",
+			content:    "---\nno topic\n",
+			maxRetries: 3,
+			want:       []EventOutcome{EventParseError, EventMovedToError},
+		},
+		{
+			name:       "invalid notifications fail fast",
+			content:    "topic\n---\nmessage\n",
+			failures:   5,
+			err:        errTooLong,
+			maxRetries: 3,
+			want:       []EventOutcome{EventParsed, EventStoreError, EventMovedToError},
+		},
+		{
+			name:     "no retries by default",
+			content:  "topic\n---\nmessage\n",
+			failures: 1,
+			want:     []EventOutcome{EventParsed, EventStoreError, EventMovedToError},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &flakyStore{fakeStore: newFakeStore(), failures: tt.failures, err: tt.err}
+			events := &eventRecorder{}
+			h := newTestHandler(t, WithStore(store), WithMaxRetries(tt.maxRetries), WithEventHandler(events.record))
+
+			path := writeFile(t, h.InputDir, "note.txt", tt.content)
+			h.process(path)
+			waitFor(t, "file to be finished", func() bool { return h.InFlight() == 0 })
+
+			got, _ := events.outcomes(path)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("outcomes = %v, want %v", got, tt.want)
+			}
+			if tt.err != nil {
+				store.mu.Lock()
+				attempts := store.attempts
+				store.mu.Unlock()
+				if attempts != 1 {
+					t.Errorf("insert attempted %d times, want once", attempts)
+				}
+			}
+
+			events.mu.Lock()
+			defer events.mu.Unlock()
+			attempt := 0
+			for _, e := range events.events {
+				if e.Outcome != EventRetrying {
+					continue
+				}
+				attempt++
+				if e.Attempt != attempt || !errors.Is(e.Err, errStoreDown) {
+					t.Errorf("retry event = %+v, want attempt %d with %v", e, attempt, errStoreDown)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+)
+
+var ErrInsecureFile = errors.New("file failed the security check")
+
+// FileSecurityPolicy constrains which files the handler is willing to read.
+type FileSecurityPolicy struct {
+	// RejectWorldWritable refuses files any user may write to.
+	RejectWorldWritable bool
+	// AllowedUIDs, if not empty, refuses files owned by any other user. It is ignored on
+	// platforms without file ownership.
+	AllowedUIDs []uint32
+}
+
+// WithFileSecurityCheck verifies the mode and owner of every file before it is read. Files
+// failing the check are not parsed but moved to the error directory, and symlinks are
+// refused altogether.
+func WithFileSecurityCheck(policy FileSecurityPolicy) Option {
+	return func(h *Handler) {
+		h.parseOptions.Security = &policy
+	}
+}
+
+// openChecked opens path for reading. With a policy, symlinks are refused and the policy is
+// checked against the opened descriptor, so the file cannot be swapped between the check
+// and the read.
+func openChecked(path string, policy *FileSecurityPolicy) (*os.File, error) {
+	if policy == nil {
+		return os.Open(path)
+	}
+	linfo, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if linfo.Mode()&fs.ModeSymlink != 0 {
+		return nil, fmt.Errorf("%w: %s is a symlink", ErrInsecureFile, path)
+	}
+
+	f, err := openNoFollow(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		err = policy.check(path, info)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (policy *FileSecurityPolicy) check(path string, info os.FileInfo) error {
+	if policy.RejectWorldWritable && info.Mode().Perm()&0o002 != 0 {
+		return fmt.Errorf("%w: %s is world-writable (%s)", ErrInsecureFile, path, info.Mode().Perm())
+	}
+	if len(policy.AllowedUIDs) > 0 {
+		if uid, ok := fileOwner(info); ok && !slices.Contains(policy.AllowedUIDs, uid) {
+			return fmt.Errorf("%w: %s is owned by uid %d", ErrInsecureFile, path, uid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package exchange
+
+import "os"
+
+func fileOwner(info os.FileInfo) (uint32, bool) {
+	return 0, false
+}
+
+func openNoFollow(path string) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,94 @@
+//go:build unix
+
+package exchange
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSecurityCheck(t *testing.T) {
+	uid := uint32(os.Getuid())
+
+	tests := []struct {
+		name    string
+		policy  *FileSecurityPolicy
+		mode    os.FileMode
+		wantErr bool
+	}{
+		{name: "disabled", policy: nil, mode: 0o666},
+		{name: "world-writable", policy: &FileSecurityPolicy{RejectWorldWritable: true}, mode: 0o666, wantErr: true},
+		{name: "group-writable", policy: &FileSecurityPolicy{RejectWorldWritable: true}, mode: 0o664},
+		{name: "allowed owner", policy: &FileSecurityPolicy{AllowedUIDs: []uint32{uid}}, mode: 0o644},
+		{name: "unexpected owner", policy: &FileSecurityPolicy{AllowedUIDs: []uint32{uid + 1}}, mode: 0o644, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "notif.txt", "topic\n---\nmessage\n")
+			if err := os.Chmod(path, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := openChecked(path, tt.policy)
+			if err == nil {
+				f.Close()
+			}
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("openChecked() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInsecureFile) {
+				t.Errorf("error %v does not wrap ErrInsecureFile", err)
+			}
+		})
+	}
+}
+
+func TestWorldWritableFileIsRejected(t *testing.T) {
+	h := newTestHandler(t, WithFileSecurityCheck(FileSecurityPolicy{RejectWorldWritable: true}))
+	path := writeFile(t, h.InputDir, "injected.txt", "topic\n---\nmessage\n")
+	if err := os.Chmod(path, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	h.process(path)
+	waitFor(t, "file to be rejected", func() bool {
+		_, err := os.Stat(filepath.Join(h.ErrorDir, "injected.txt"))
+		return err == nil
+	})
+	if got := h.Processed(); got != 0 {
+		t.Errorf("Processed() = %d, want 0", got)
+	}
+}
+
+func TestSymlinkIsRejected(t *testing.T) {
+	h := newTestHandler(t, WithFileSecurityCheck(FileSecurityPolicy{RejectWorldWritable: true}))
+	target := writeFile(t, t.TempDir(), "target.txt", "topic\n---\nmessage\n")
+	path := filepath.Join(h.InputDir, "link.txt")
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openChecked(path, h.parseOptions.Security); !errors.Is(err, ErrInsecureFile) {
+		t.Fatalf("openChecked() error = %v, want ErrInsecureFile", err)
+	}
+	if _, err := h.ReadHead(path); !errors.Is(err, ErrInsecureFile) {
+		t.Fatalf("ReadHead() error = %v, want ErrInsecureFile", err)
+	}
+	if f, err := openNoFollow(path); err == nil || !errors.Is(err, ErrInsecureFile) {
+		if f != nil {
+			f.Close()
+		}
+		t.Fatalf("openNoFollow() error = %v, want ErrInsecureFile", err)
+	}
+
+	h.process(path)
+	waitFor(t, "symlink to be rejected", func() bool {
+		_, err := os.Lstat(filepath.Join(h.ErrorDir, "link.txt"))
+		return err == nil
+	})
+	if got := h.Processed(); got != 0 {
+		t.Errorf("Processed() = %d, want 0", got)
+	}
+}
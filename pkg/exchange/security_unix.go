@@ -0,0 +1,27 @@
+//go:build unix
+
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func fileOwner(info os.FileInfo) (uint32, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return stat.Uid, true
+}
+
+// openNoFollow fails if path was replaced by a symlink after it was checked.
+func openNoFollow(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if errors.Is(err, syscall.ELOOP) {
+		return nil, fmt.Errorf("%w: %s is a symlink", ErrInsecureFile, path)
+	}
+	return f, err
+}
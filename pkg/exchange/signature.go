@@ -0,0 +1,78 @@
+package exchange
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SignatureDeviceKey names the registered device that signed the file.
+	SignatureDeviceKey = "device"
+	// SignatureMetadataKey carries the base64 Ed25519 signature over the message.
+	SignatureMetadataKey = "signature"
+)
+
+// SigningKeyStore looks up the Ed25519 key a device signs files with, as stored. It is not
+// the key notifications are encrypted to for that device.
+type SigningKeyStore interface {
+	GetDeviceSigningKey(ctx context.Context, deviceID string) (string, error)
+}
+
+// WithSignatureVerification only accepts files whose message is signed by a registered
+// device. The signing device is named in SignatureDeviceKey and its Ed25519 signature in
+// SignatureMetadataKey, both base64 encoded. Unsigned files and failed verifications are
+// moved to the error directory with a SignatureError.
+func WithSignatureVerification(keys SigningKeyStore) Option {
+	return func(h *Handler) {
+		h.signingKeys = keys
+	}
+}
+
+// verifySignature runs on the notification as parsed, before enrichments can drop or
+// rewrite the keys it depends on.
+func (h *Handler) verifySignature(ctx context.Context, notif *Notification) error {
+	if h.signingKeys == nil {
+		return nil
+	}
+
+	device := notif.Metadata[SignatureDeviceKey]
+	signature := notif.Metadata[SignatureMetadataKey]
+	if device == "" || signature == "" {
+		return &SignatureError{Device: device, Err: errors.New("file is not signed")}
+	}
+
+	rawKey, err := h.signingKeys.GetDeviceSigningKey(ctx, device)
+	if err != nil {
+		return &SignatureError{Device: device, Err: fmt.Errorf("failed to get signing key: %w", err)}
+	}
+	key, err := decodeBase64(rawKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return &SignatureError{Device: device, Err: errors.New("signing key is not an Ed25519 key")}
+	}
+	sig, err := decodeBase64(signature)
+	if err != nil {
+		return &SignatureError{Device: device, Err: fmt.Errorf("failed to decode signature: %w", err)}
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(key), []byte(notif.Message), sig) {
+		return &SignatureError{Device: device, Err: errors.New("signature does not match")}
+	}
+	return nil
+}
+
+// decodeBase64 accepts standard and URL-safe base64, padded or not.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		var b []byte
+		if b, err = enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,85 @@
+package exchange
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeKeyStore map[string]string
+
+func (s fakeKeyStore) GetDeviceSigningKey(ctx context.Context, deviceID string) (string, error) {
+	key, ok := s[deviceID]
+	if !ok {
+		return "", fmt.Errorf("device %s not found", deviceID)
+	}
+	return key, nil
+}
+
+func TestSignatureVerification(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := fakeKeyStore{"laptop": base64.StdEncoding.EncodeToString(pub)}
+
+	const message = "deploy finished"
+	sign := func(key ed25519.PrivateKey) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(message)))
+	}
+
+	tests := []struct {
+		name    string
+		head    string
+		wantErr bool
+	}{
+		{name: "valid", head: "device: laptop\nsignature: " + sign(priv)},
+		{name: "bad signature", head: "device: laptop\nsignature: " + sign(otherPriv), wantErr: true},
+		{name: "unknown device", head: "device: phone\nsignature: " + sign(priv), wantErr: true},
+		{name: "unsigned", head: "device: laptop", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, WithSignatureVerification(keys))
+			path := writeFile(t, h.InputDir, "signed.txt", "deploys\n"+tt.head+"\n---\n"+message)
+
+			proc := &Process{Filepath: path}
+			err := h.prepare(context.Background(), proc)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("prepare() error = %v", err)
+				}
+				return
+			}
+			var sigErr *SignatureError
+			if !errors.As(err, &sigErr) {
+				t.Fatalf("prepare() error = %v, want a SignatureError", err)
+			}
+			if sigErr.File != path {
+				t.Errorf("SignatureError.File = %q, want %q", sigErr.File, path)
+			}
+		})
+	}
+}
+
+func TestUnsignedFileMovedToErrorDir(t *testing.T) {
+	h := newTestHandler(t, WithSignatureVerification(fakeKeyStore{}))
+	h.process(writeFile(t, h.InputDir, "unsigned.txt", "deploys\n---\nmessage\n"))
+
+	waitFor(t, "file in error dir", func() bool {
+		_, err := os.Stat(filepath.Join(h.ErrorDir, "unsigned.txt"))
+		return err == nil
+	})
+	if got := h.Processed(); got != 0 {
+		t.Errorf("Processed() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,21 @@
+package exchange
+
+import "log/slog"
+
+// WithLargeMessageWarnThreshold logs a warning and counts every notification whose message
+// is longer than threshold bytes in cland_large_messages_total, see WithMetrics. It is a soft
+// signal only, such messages are still processed.
+func WithLargeMessageWarnThreshold(threshold int) Option {
+	return func(h *Handler) {
+		h.largeMessageThreshold = threshold
+	}
+}
+
+func (h *Handler) checkMessageSize(notif *Notification, path string) {
+	if h.largeMessageThreshold <= 0 || len(notif.Message) <= h.largeMessageThreshold {
+		return
+	}
+	h.largeMessages.Inc()
+	slog.Warn("Large notification message", "file", path, "topic", notif.Topic,
+		"size", len(notif.Message), "threshold", h.largeMessageThreshold)
+}
@@ -0,0 +1,100 @@
+package exchange
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func largeMessages(t *testing.T, reg *prometheus.Registry) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "cland_large_messages_total" {
+			return family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	t.Fatal("cland_large_messages_total is not registered")
+	return 0
+}
+
+func TestLargeMessageWarning(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		size      int
+		warn      bool
+	}{
+		{name: "below threshold", threshold: 10, size: 9, warn: false},
+		{name: "at threshold", threshold: 10, size: 10, warn: false},
+		{name: "above threshold", threshold: 10, size: 11, warn: true},
+		{name: "disabled", threshold: 0, size: 1 << 20, warn: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			reg := prometheus.NewRegistry()
+			h := newTestHandler(t, WithLargeMessageWarnThreshold(tt.threshold), WithMetrics(reg))
+
+			notif := &Notification{Topic: "big/topic", Message: strings.Repeat("x", tt.size)}
+			h.checkMessageSize(notif, "input/file.txt")
+
+			warned := strings.Contains(logs.String(), "level=WARN")
+			if warned != tt.warn {
+				t.Errorf("warning logged = %v, want %v: %s", warned, tt.warn, logs.String())
+			}
+			if tt.warn && !strings.Contains(logs.String(), "topic=big/topic") {
+				t.Errorf("warning does not include topic: %s", logs.String())
+			}
+			if want := map[bool]float64{true: 1, false: 0}[tt.warn]; largeMessages(t, reg) != want {
+				t.Errorf("cland_large_messages_total = %v, want %v", largeMessages(t, reg), want)
+			}
+		})
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	dir := t.TempDir()
+	content := "topic\n---\n" + strings.Repeat("x", 90)
+
+	atLimit := filepath.Join(dir, "limit.txt")
+	if err := os.WriteFile(atLimit, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Process{Filepath: atLimit, Options: ParseOptions{MaxFileSize: int64(len(content))}}
+	if err := p.ReadFile(); err != nil {
+		t.Fatalf("ReadFile() at the limit error = %v", err)
+	}
+
+	over := filepath.Join(dir, "over.txt")
+	if err := os.WriteFile(over, []byte(content+"x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p = &Process{Filepath: over, Options: ParseOptions{MaxFileSize: int64(len(content))}}
+	err := p.ReadFile()
+	var tooLarge *FileTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("ReadFile() over the limit error = %v, want a FileTooLargeError", err)
+	}
+	if tooLarge.File != over || tooLarge.Limit != int64(len(content)) {
+		t.Errorf("FileTooLargeError = %+v", tooLarge)
+	}
+}
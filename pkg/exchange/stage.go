@@ -0,0 +1,22 @@
+package exchange
+
+// ParseStage transforms or validates a parsed notification. Returning an error fails the
+// file, which is then moved to the error directory.
+type ParseStage func(*Notification) error
+
+// WithParseStages runs stages in order on every notification after it was parsed and
+// enriched. The first failing stage stops the pipeline.
+func WithParseStages(stages ...ParseStage) Option {
+	return func(h *Handler) {
+		h.parseStages = append(h.parseStages, stages...)
+	}
+}
+
+func (h *Handler) runStages(notif *Notification) error {
+	for _, stage := range h.parseStages {
+		if err := stage(notif); err != nil {
+			return err
+		}
+	}
+	return nil
+}
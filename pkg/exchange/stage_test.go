@@ -0,0 +1,68 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseStages(t *testing.T) {
+	errForbidden := errors.New("forbidden topic")
+	var calls []string
+
+	enrich := func(n *Notification) error {
+		calls = append(calls, "enrich:"+n.Topic)
+		n.Metadata["source"] = "stage"
+		return nil
+	}
+	reject := func(n *Notification) error {
+		calls = append(calls, "reject:"+n.Topic)
+		if n.Topic == "forbidden" {
+			return errForbidden
+		}
+		return nil
+	}
+	last := func(n *Notification) error {
+		calls = append(calls, "last:"+n.Topic)
+		return nil
+	}
+	h := newTestHandler(t, WithParseStages(enrich, reject), WithParseStages(last))
+
+	t.Run("all stages run in order", func(t *testing.T) {
+		calls = nil
+		proc := &Process{Filepath: writeFile(t, h.InputDir, "ok.txt", "allowed\n---\nmessage\n")}
+		if err := h.prepare(context.Background(), proc); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"enrich:allowed", "reject:allowed", "last:allowed"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("calls = %v, want %v", calls, want)
+		}
+		if proc.Notif.Metadata["source"] != "stage" {
+			t.Errorf("enriching stage did not apply, metadata = %v", proc.Notif.Metadata)
+		}
+	})
+
+	t.Run("failing stage short-circuits", func(t *testing.T) {
+		calls = nil
+		proc := &Process{Filepath: writeFile(t, h.InputDir, "bad.txt", "forbidden\n---\nmessage\n")}
+		if err := h.prepare(context.Background(), proc); !errors.Is(err, errForbidden) {
+			t.Fatalf("prepare() error = %v, want %v", err, errForbidden)
+		}
+		want := []string{"enrich:forbidden", "reject:forbidden"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("calls = %v, want %v", calls, want)
+		}
+	})
+
+	t.Run("failing stage routes to error dir", func(t *testing.T) {
+		h.process(writeFile(t, h.InputDir, "routed.txt", "forbidden\n---\nmessage\n"))
+		waitFor(t, "file in error dir", func() bool {
+			_, err := os.Stat(filepath.Join(h.ErrorDir, "routed.txt"))
+			return err == nil
+		})
+	})
+}
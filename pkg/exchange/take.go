@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// TakeErrorFiles moves up to limit files out of the error directory and its priority
+// subdirectories into dst and returns their new paths. Files from a priority subdirectory
+// keep it, error/critical/a.txt is taken to dst/critical/a.txt. Once taken, cland no longer
+// manages the files, so an external tool can repair and resubmit them without racing
+// anything that works on ErrorDir, and the WithErrorRetention sweep never prunes them. A
+// limit of zero or less takes every file. dst must be on the same filesystem as ErrorDir
+// and existing files in dst are never overwritten.
+func (h *Handler) TakeErrorFiles(dst string, limit int) ([]string, error) {
+	h.takeMu.Lock()
+	defer h.takeMu.Unlock()
+
+	dirs, err := h.errorDirs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read error dir: %w", err)
+	}
+
+	taken := make([]string, 0)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return taken, fmt.Errorf("failed to read error dir: %w", err)
+		}
+
+		for _, entry := range entries {
+			if limit > 0 && len(taken) >= limit {
+				break
+			}
+			if !entry.Type().IsRegular() {
+				continue
+			}
+
+			from := filepath.Join(dir, entry.Name())
+			rel, err := filepath.Rel(h.ErrorDir, from)
+			if err != nil {
+				return taken, fmt.Errorf("failed to take %s: %w", entry.Name(), err)
+			}
+			to := filepath.Join(dst, rel)
+			if _, err := os.Lstat(to); err == nil {
+				return taken, fmt.Errorf("failed to take %s: %s already exists", rel, to)
+			}
+			if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+				return taken, fmt.Errorf("failed to take %s: %w", rel, err)
+			}
+			if err := os.Rename(from, to); err != nil {
+				if os.IsNotExist(err) {
+					// Gone between ReadDir and Rename, someone else got there first.
+					continue
+				}
+				return taken, fmt.Errorf("failed to take %s: %w", rel, err)
+			}
+			taken = append(taken, to)
+		}
+	}
+
+	slog.Info("Took error files", "count", len(taken), "dst", dst)
+	return taken, nil
+}
@@ -0,0 +1,189 @@
+package exchange
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTakeErrorFiles(t *testing.T) {
+	h := newTestHandler(t)
+	dst := t.TempDir()
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		writeFile(t, h.ErrorDir, name, "broken")
+	}
+
+	taken, err := h.TakeErrorFiles(dst, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taken) != 2 {
+		t.Fatalf("took %d files, want 2", len(taken))
+	}
+	for _, path := range taken {
+		if filepath.Dir(path) != dst {
+			t.Errorf("taken path %s is not in %s", path, dst)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("taken file missing: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(h.ErrorDir, filepath.Base(path))); !os.IsNotExist(err) {
+			t.Errorf("%s is still in the error dir", filepath.Base(path))
+		}
+	}
+
+	rest, err := h.TakeErrorFiles(dst, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 1 {
+		t.Fatalf("took %d remaining files, want 1", len(rest))
+	}
+	entries, _ := os.ReadDir(h.ErrorDir)
+	if len(entries) != 0 {
+		t.Errorf("error dir still has %d files", len(entries))
+	}
+}
+
+func TestTakeErrorFilesFromPriorityDirs(t *testing.T) {
+	h := newTestHandler(t)
+	dst := t.TempDir()
+
+	writeFile(t, h.ErrorDir, "flat.txt", "broken")
+	for _, label := range []string{"critical", "7"} {
+		if err := os.MkdirAll(filepath.Join(h.ErrorDir, label), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(h.ErrorDir, label), "urgent.txt", "broken")
+	}
+
+	taken, err := h.TakeErrorFiles(dst, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(taken)
+	want := []string{
+		filepath.Join(dst, "7", "urgent.txt"),
+		filepath.Join(dst, "critical", "urgent.txt"),
+		filepath.Join(dst, "flat.txt"),
+	}
+	if !reflect.DeepEqual(taken, want) {
+		t.Fatalf("took %v, want %v", taken, want)
+	}
+	for _, path := range taken {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("taken file missing: %v", err)
+		}
+	}
+	for _, label := range []string{"critical", "7"} {
+		if entries, _ := os.ReadDir(filepath.Join(h.ErrorDir, label)); len(entries) != 0 {
+			t.Errorf("error/%s still has %d files", label, len(entries))
+		}
+	}
+}
+
+func TestTakeErrorFilesConcurrent(t *testing.T) {
+	h := newTestHandler(t)
+	const n = 20
+	for i := 0; i < n; i++ {
+		writeFile(t, h.ErrorDir, string(rune('a'+i))+".txt", "broken")
+	}
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		all []string
+	)
+	for i := 0; i < 4; i++ {
+		dst := t.TempDir()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			taken, err := h.TakeErrorFiles(dst, 0)
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			all = append(all, taken...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	names := make([]string, 0, len(all))
+	for _, path := range all {
+		names = append(names, filepath.Base(path))
+	}
+	sort.Strings(names)
+	if len(names) != n {
+		t.Fatalf("took %d files in total, want %d", len(names), n)
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i] == names[i-1] {
+			t.Errorf("%s was taken twice", names[i])
+		}
+	}
+}
+
+func TestTakeErrorFilesNoOverwrite(t *testing.T) {
+	h := newTestHandler(t)
+	dst := t.TempDir()
+	writeFile(t, h.ErrorDir, "same.txt", "broken")
+	writeFile(t, dst, "same.txt", "already here")
+
+	if _, err := h.TakeErrorFiles(dst, 0); err == nil {
+		t.Fatal("TakeErrorFiles overwrote an existing file without error")
+	}
+	content, _ := os.ReadFile(filepath.Join(dst, "same.txt"))
+	if string(content) != "already here" {
+		t.Errorf("existing file was overwritten: %q", content)
+	}
+}
+
+func TestTakenFilesSurviveRetention(t *testing.T) {
+	h := newTestHandler(t, WithErrorRetention(24*time.Hour))
+	dst := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+
+	writeFile(t, h.ErrorDir, "taken.txt", "broken")
+	if err := os.Chtimes(filepath.Join(h.ErrorDir, "taken.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	taken, err := h.TakeErrorFiles(dst, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taken) != 1 {
+		t.Fatalf("took %d files, want 1", len(taken))
+	}
+
+	if err := os.MkdirAll(filepath.Join(h.ErrorDir, "critical"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"stale.txt", filepath.Join("critical", "stale.txt")} {
+		writeFile(t, h.ErrorDir, name, "broken")
+		if err := os.Chtimes(filepath.Join(h.ErrorDir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, h.ErrorDir, "fresh.txt", "broken")
+
+	h.pruneErrorFiles(time.Now())
+
+	if _, err := os.Stat(taken[0]); err != nil {
+		t.Errorf("taken file was pruned: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(h.ErrorDir, "fresh.txt")); err != nil {
+		t.Errorf("fresh error file was pruned: %v", err)
+	}
+	for _, name := range []string{"stale.txt", filepath.Join("critical", "stale.txt")} {
+		if _, err := os.Stat(filepath.Join(h.ErrorDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not pruned", name)
+		}
+	}
+}